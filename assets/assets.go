@@ -0,0 +1,9 @@
+// Package assets embeds distributions, ddl and query files into the binary, so that it runs from any working directory
+package assets
+
+import "embed"
+
+// FS files under ./data, ex: data/dists.dss
+//
+//go:embed data
+var FS embed.FS
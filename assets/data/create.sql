@@ -15,7 +15,8 @@ CREATE source lineitem (
     l_receiptdate DATE,
     l_shipinstruct CHAR(25),
     l_shipmode CHAR(10),
-    l_comment VARCHAR(44))
+    l_comment VARCHAR(44),
+    l_eventtime TIMESTAMP)
     with (
     'upstream.source' = 'kafka',
     'kafka.topic' = 'lineitem',
@@ -94,7 +95,8 @@ create source orders (
     o_orderpriority CHAR(15) NOT NULL,
     o_clerk CHAR(15) NOT NULL,
     o_shippriority INTEGER NOT NULL,
-    o_comment VARCHAR(79) NOT NULL)
+    o_comment VARCHAR(79) NOT NULL,
+    o_eventtime TIMESTAMP)
     with (
     'upstream.source' = 'kafka',
     'kafka.topic' = 'orders',
@@ -15,7 +15,8 @@ CREATE source lineitem (
     l_receiptdate DATE,
     l_shipinstruct VARCHAR(25),
     l_shipmode VARCHAR(10),
-    l_comment VARCHAR(44))
+    l_comment VARCHAR(44),
+    l_eventtime TIMESTAMP)
     with (
     'connector'='kafka',
     'kafka.topic'='lineitem',
@@ -98,7 +99,8 @@ create source orders (
     o_orderpriority VARCHAR(15) NOT NULL,
     o_clerk VARCHAR(15) NOT NULL,
     o_shippriority INTEGER NOT NULL,
-    o_comment VARCHAR(79) NOT NULL)
+    o_comment VARCHAR(79) NOT NULL,
+    o_eventtime TIMESTAMP)
     with (
     'connector'='kafka',
     'kafka.topic'='orders',
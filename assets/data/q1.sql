@@ -1,3 +1,6 @@
+# tables: lineitem
+# main: lineitem
+# mv: tpch_q1
 statement
 create materialized view tpch_q1 as
 select
@@ -1,3 +1,6 @@
+# tables: customer, orders, lineitem, nation
+# main: lineitem
+# mv: tpch_q10
 statement
 create materialized view tpch_q10 as
 select
@@ -0,0 +1,33 @@
+# tables: partsupp, supplier, nation
+# main: partsupp
+# mv: tpch_q11
+statement
+create materialized view tpch_q11 as
+select
+	ps_partkey,
+	sum(ps_supplycost * ps_availqty) as value
+from
+	partsupp,
+	supplier,
+	nation
+where
+	ps_suppkey = s_suppkey
+	and s_nationkey = n_nationkey
+	and n_name = 'GERMANY'
+group by
+	ps_partkey
+having
+	sum(ps_supplycost * ps_availqty) > (
+		select
+			sum(ps_supplycost * ps_availqty) * 0.0001
+		from
+			partsupp,
+			supplier,
+			nation
+		where
+			ps_suppkey = s_suppkey
+			and s_nationkey = n_nationkey
+			and n_name = 'GERMANY'
+	)
+order by
+	value desc;
\ No newline at end of file
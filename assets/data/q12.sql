@@ -1,3 +1,6 @@
+# tables: orders, lineitem
+# main: lineitem
+# mv: tpch_q12
 statement
 create materialized view tpch_q12 as
 select
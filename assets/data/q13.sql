@@ -1,3 +1,6 @@
+# tables: customer, orders
+# main: orders
+# mv: tpch_q13
 statement
 create materialized view tpch_q13 as
 select
@@ -1,3 +1,6 @@
+# tables: lineitem, part
+# main: lineitem
+# mv: tpch_q17
 statement
 create materialized view tpch_q17 as
 select
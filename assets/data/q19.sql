@@ -1,3 +1,6 @@
+# tables: lineitem, part
+# main: lineitem
+# mv: tpch_q19
 statement
 create materialized view tpch_q19 as
 select
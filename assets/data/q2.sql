@@ -1,3 +1,6 @@
+# tables: part, supplier, partsupp, nation, region
+# main: partsupp
+# mv: tpch_q2
 statement
 create materialized view tpch_q2 as
 select
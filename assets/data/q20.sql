@@ -1,3 +1,6 @@
+# tables: supplier, nation, partsupp, part, lineitem
+# main: lineitem
+# mv: tpch_q20
 statement
 create materialized view tpch_q20 as
 select
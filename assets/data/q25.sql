@@ -1,3 +1,7 @@
+# tables: lineitem
+# main: lineitem
+# mv: large_state
+# sample: false
 statement
 create materialized view large_state as
 select * from lineitem;
\ No newline at end of file
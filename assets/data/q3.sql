@@ -1,3 +1,6 @@
+# tables: customer, orders, lineitem
+# main: lineitem
+# mv: tpch_q3
 statement
 create materialized view tpch_q3 as
 select
@@ -1,3 +1,6 @@
+# tables: lineitem, orders
+# main: lineitem
+# mv: tpch_q4
 statement
 create materialized view tpch_q4 as
 select
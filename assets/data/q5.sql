@@ -1,3 +1,6 @@
+# tables: customer, orders, lineitem, supplier, nation, region
+# main: lineitem
+# mv: tpch_q5
 statement
 create materialized view tpch_q5 as
 select
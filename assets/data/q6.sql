@@ -1,3 +1,6 @@
+# tables: lineitem
+# main: lineitem
+# mv: tpch_q6
 statement
 create materialized view tpch_q6 as
 select
@@ -1,3 +1,6 @@
+# tables: supplier, lineitem, orders, customer, nation
+# main: lineitem
+# mv: tpch_q7
 statement
 create materialized view tpch_q7 as
 select
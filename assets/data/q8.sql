@@ -1,3 +1,6 @@
+# tables: part, supplier, lineitem, orders, customer, nation, region
+# main: lineitem
+# mv: tpch_q8
 statement
 create materialized view tpch_q8 as
 select
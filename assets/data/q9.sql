@@ -1,3 +1,6 @@
+# tables: part, supplier, lineitem, partsupp, orders, nation
+# main: lineitem
+# mv: tpch_q9
 statement
 create materialized view tpch_q9 as
 select
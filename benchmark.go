@@ -1,187 +1,864 @@
 package tpch_bench
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"github.com/singularity-data/tpch-bench/pkg/configs"
 	"github.com/singularity-data/tpch-bench/pkg/exec"
 	"github.com/singularity-data/tpch-bench/pkg/metric"
 	"github.com/singularity-data/tpch-bench/pkg/util"
-	"path/filepath"
+	"strings"
 	"time"
 )
 
 type Benchmark struct {
 	db             *sql.DB
-	metricsManager *metric.MetricsManager
+	metricsManager *metric.MetricsManager // metrics of the current query
+	suite          *metric.SuiteReport    // nil unless a suite is run
+	scenario       configs.Scenario       // echoed into the report
 }
 
 func NewBenchmark(db *sql.DB) *Benchmark {
 	return &Benchmark{
 		db,
 		metric.NewMetricsManager(),
+		nil,
+		nil,
 	}
 }
 
-func (b *Benchmark) RunTpchStd(queryId int, rate int, scale float64) {
+// SetScenario the resolved scenario of the run is echoed into the report
+func (b *Benchmark) SetScenario(scenario configs.Scenario) {
+	b.scenario = scenario
+}
+
+// RunTpchStd returns the error that stops the run, the result not converging is also an error
+func (b *Benchmark) RunTpchStd(ctx context.Context, query *configs.Query, rate int, scale float64) error {
+	util.SetField("query", query.Name)
+	phase("setup")
 	util.LogInfo("------Prepare to run tpch query------")
-	sqlConfig := configs.NewTpchSqlConfig(queryId)
-	tpchConfig := configs.NewTpchConfig(queryId, rate, scale)
+	sqlConfig := configs.NewTpchSqlConfig(query)
+	tpchConfig := configs.NewTpchConfig(query, rate, scale)
 
 	// create all topics in Kafka
 	err := exec.AdminTopics("create")
 	if err != nil {
-		util.LogErr(err.Error())
+		return setupError(err)
 	}
 
 	// prepare tpch data generator
 	kafkaExec := exec.NewQueryKafkaExecutor(tpchConfig)
 	err = kafkaExec.Prepare()
 	if err != nil {
-		util.LogErr(err.Error())
-		return
+		return produceError(err)
 	}
 
 	// create all source tables in RisingWave
-	util.LogInfo("------Create all source tables in RisingWave------")
-	paths, err := filepath.Glob(sqlConfig.SqlCreatePathPattern)
+	err = b.createSources(ctx, sqlConfig)
 	if err != nil {
-		util.LogErr("parse sql create file path err: %s", err.Error())
-		return
+		return setupError(err)
 	}
-	err = b.runSQLFiles(paths, configs.SQLCreateSource)
+
+	// send data rows of small tables in advance
+	phase("batch")
+	err = kafkaExec.SendKafkaBatch(ctx)
 	if err != nil {
-		util.LogErr("Create source tables error: %s", err.Error())
-		return
+		return produceError(err)
 	}
 
-	// send data rows of small tables in advance
-	kafkaExec.SendKafkaBatch()
+	// wait until small tables are ingested, or early join results depend on race timing
+	phase("ingest")
+	err = b.waitIngested(ctx, kafkaExec.BatchRows())
+	if err != nil {
+		return setupError(err)
+	}
 
 	// create mv related to a specific tpch query
-	util.LogInfo("------Create MV for q%d------", queryId)
-	paths, err = filepath.Glob(sqlConfig.SqlQueryPathPattern)
+	phase("setup")
+	err = b.createMVs(ctx, query, sqlConfig)
 	if err != nil {
-		util.LogErr("parse sql mv query file path err: %s", err.Error())
-		return
+		return setupError(err)
 	}
-	err = b.runSQLFiles(paths, configs.SQLNormal)
+
+	// freshness probes flow through a side mv while streaming
+	stopProbes, err := b.startProbes(ctx)
 	if err != nil {
-		util.LogErr(err.Error())
-		return
+		return setupError(err)
+	}
+
+	// output changes of the mv are consumed from a sink while streaming
+	stopSink, err := b.startSink(ctx, query, kafkaExec)
+	if err != nil {
+		stopProbes()
+		return setupError(err)
+	}
+
+	// sample results while sending data rows of main table in realtime
+	sampleCtx, cancelSample := context.WithCancel(ctx)
+	defer cancelSample()
+	produced := make(chan time.Time, 1)
+	sampled := make(chan error, 1)
+	go func() {
+		sampled <- b.sampleResults(sampleCtx, query, produced, kafkaExec.ObserveMVRows)
+	}()
+	phase("stream")
+	start := time.Now()
+	rows, produceErr := kafkaExec.SendKafkaRealTime(ctx)
+	produced <- time.Now()
+	phase("converge")
+	elapsed := time.Now().Sub(start).Seconds()
+	b.metricsManager.Record(metric.ProduceSeconds, elapsed)
+	b.metricsManager.Record(metric.ProducedRows, float64(rows))
+	if elapsed > 0 {
+		b.metricsManager.Record(metric.ProduceRate, float64(rows)/elapsed)
 	}
+	stopProbes()
+	stopSink()
 
-	// send data rows of main table in realtime
-	kafkaExec.SendKafkaRealTime()
+	if produceErr != nil {
+		// the result of partial input is not worth waiting for
+		cancelSample()
+		<-sampled
+		return produceError(produceErr)
+	}
+	return <-sampled
+}
 
-	// check results
-	err = b.checkResults(queryId)
+// createSources creates all source tables in RisingWave
+func (b *Benchmark) createSources(ctx context.Context, sqlConfig *configs.SqlConfig) error {
+	util.LogInfo("------Create all source tables in RisingWave------")
+	paths, err := configs.GlobAssets(sqlConfig.SqlCreatePathPattern)
 	if err != nil {
-		util.LogErr(err.Error())
+		return util.Errorf("parse sql create file path err: %s", err.Error())
+	}
+	if len(paths) == 0 {
+		return util.Errorf("no sql file matches %s", sqlConfig.SqlCreatePathPattern)
+	}
+	err = b.runSQLFiles(ctx, paths, configs.SQLCreateSource)
+	if err != nil {
+		return util.Errorf("Create source tables error: %s", err.Error())
+	}
+	return nil
+}
+
+// createMVs creates mvs related to a specific tpch query
+func (b *Benchmark) createMVs(ctx context.Context, query *configs.Query, sqlConfig *configs.SqlConfig) error {
+	if query.Path == "" {
+		return nil
+	}
+	util.LogInfo("------Create MV for %s------", query.Name)
+	paths, err := configs.GlobAssets(sqlConfig.SqlQueryPathPattern)
+	if err != nil {
+		return util.Errorf("parse sql mv query file path err: %s", err.Error())
+	}
+	if len(paths) == 0 {
+		return util.Errorf("no sql file matches %s", sqlConfig.SqlQueryPathPattern)
 	}
+	return b.runSQLFiles(ctx, paths, configs.SQLNormal)
 }
 
-func (b *Benchmark) CleanTpchAll(queryId int) {
+// RunSuite runs tpch-std for every query in order, each one from fresh source tables and topics.
+// Every query is cleaned up after it's run, and the suite continues past failed queries.
+// The error of the first failed query is returned
+func (b *Benchmark) RunSuite(ctx context.Context, queries []*configs.Query, rate int, scale float64) error {
+	b.suite = metric.NewSuiteReport()
+	b.suite.Scenario = b.scenario
+	failures := 0
+	var firstErr error
+	for i, query := range queries {
+		report := &metric.QueryReport{
+			Query: query.Name,
+		}
+		b.suite.Queries = append(b.suite.Queries, report)
+		if ctx.Err() != nil {
+			report.Status = metric.StatusSkipped
+			continue
+		}
+
+		util.LogInfo("------Run %s [%d/%d] of the suite------", query.Name, i+1, len(queries))
+		b.metricsManager = metric.NewMetricsManager()
+		err := b.RunTpchStd(ctx, query, rate, scale)
+		interrupted := ctx.Err() != nil
+		if interrupted {
+			b.metricsManager.MarkInterrupted()
+		}
+		report.Report = b.metricsManager.Report()
+		report.Status = metric.QueryStatus(err, interrupted, report.Report)
+		if err != nil && !interrupted {
+			report.Error = err.Error()
+			util.LogErr("%s failed: %s", query.Name, err.Error())
+			failures++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+
+		if !interrupted || configs.CleanupOnInterrupt {
+			// the run may be interrupted, so cleanup has its own deadline
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), configs.CleanupTimeout)
+			err = b.CleanTpchAll(cleanupCtx, query)
+			cancel()
+			if err != nil {
+				util.LogErr("clean %s error: %s", query.Name, err.Error())
+			}
+		}
+	}
+	util.SetField("query", "")
+	phase("")
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if firstErr != nil {
+		return fmt.Errorf("%d of %d queries fail, the first one: %w", failures, len(queries), firstErr)
+	}
+	return nil
+}
+
+// CleanTpchAll drops everything created for the query and deletes all topics,
+// it keeps cleaning after errors and returns the first one
+func (b *Benchmark) CleanTpchAll(ctx context.Context, query *configs.Query) error {
+	util.SetField("query", query.Name)
+	phase("clean")
 	util.LogInfo("------Prepare to clean RisingWave and Kafka------")
+	var firstErr error
+	keep := func(err error) {
+		if err == nil {
+			return
+		}
+		util.LogErr(err.Error())
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
 
-	if queryId != -1 {
-		// drop mv related to a specific tpch query
+	if query.Name != configs.AllTablesQuery {
 		executor := exec.NewSQLExecutor(b.db)
-		err := executor.ExecuteSQLStatement(fmt.Sprintf("DROP MATERIALIZED VIEW tpch_q%d", queryId))
+		// the sink exists only if it was enabled
+		if mv := query.ResultMV(); mv != "" {
+			err := executor.ExecuteSQLStatement(ctx, fmt.Sprintf("DROP SINK %s", exec.SinkName(mv)))
+			if err != nil {
+				util.LogInfo("no sink is dropped: %s", err.Error())
+			} else {
+				keep(exec.AdminSinkTopic("delete", exec.SinkName(mv)))
+			}
+		}
+
+		// drop mvs related to the query, later ones may depend on earlier ones
+		for i := len(query.MVs) - 1; i >= 0; i-- {
+			keep(executor.ExecuteSQLStatement(ctx, fmt.Sprintf("DROP MATERIALIZED VIEW %s", query.MVs[i])))
+		}
+
+		// ready mvs are left only if a run fails to drop them
+		for _, table := range query.Tables {
+			err := executor.ExecuteSQLStatement(ctx, fmt.Sprintf("DROP MATERIALIZED VIEW %s", readyMV(table)))
+			if err != nil {
+				util.LogDebug("no ready mv of %s is dropped: %s", table, err.Error())
+			}
+		}
+
+		// the probe mv exists only if probes were sent
+		err := executor.ExecuteSQLStatement(ctx, fmt.Sprintf("DROP MATERIALIZED VIEW %s", exec.ProbeMV))
 		if err != nil {
-			util.LogErr(err.Error())
+			util.LogInfo("no probe mv is dropped: %s", err.Error())
 		}
 
 		// drop all source tables in RisingWave
-		sqlConfig := configs.NewTpchSqlConfig(queryId)
-		paths, err := filepath.Glob(sqlConfig.SqlDropPathPattern)
+		sqlConfig := configs.NewTpchSqlConfig(query)
+		paths, err := configs.GlobAssets(sqlConfig.SqlDropPathPattern)
 		if err != nil {
-			util.LogErr("parse sql drop file path err: %s", err.Error())
+			keep(util.Errorf("parse sql drop file path err: %s", err.Error()))
 		}
-		_ = b.runSQLFiles(paths, configs.SQLNormal)
+		keep(b.runSQLFiles(ctx, paths, configs.SQLNormal))
 	}
 
 	// drop all topics in Kafka
-	err := exec.AdminTopics("delete")
+	keep(exec.AdminTopics("delete"))
+	return firstErr
+}
+
+// RunSendKafka creates all topics and sends rows of tables of the query,
+// tables sent in realtime are sent after all others. Topics created by setup are kept
+func (b *Benchmark) RunSendKafka(ctx context.Context, query *configs.Query, rate int, scale float64) error {
+	util.SetField("query", query.Name)
+	phase("setup")
+	util.LogInfo("------Prepare to send all data to Kafka------")
+
+	// create missing topics in Kafka
+	err := exec.AdminTopics("ensure")
 	if err != nil {
-		util.LogErr(err.Error())
+		return setupError(err)
 	}
+
+	// prepare tpch data generator
+	tpchConfig := configs.NewTpchConfig(query, rate, scale)
+	kafkaExec := exec.NewQueryKafkaExecutor(tpchConfig)
+	err = kafkaExec.Prepare()
+	if err != nil {
+		return produceError(err)
+	}
+
+	phase("batch")
+	err = kafkaExec.SendKafkaBatch(ctx)
+	if err != nil {
+		return produceError(err)
+	}
+	phase("stream")
+	_, err = kafkaExec.SendKafkaRealTime(ctx)
+	return produceError(err)
 }
 
-func (b *Benchmark) RunSendKafka(query int, rate int, scale float64) {
-	util.LogInfo("------Prepare to send all data to Kafka------")
+// RunGenerate writes rows of all tables of the query to json files in dir, nothing is sent to kafka
+func (b *Benchmark) RunGenerate(ctx context.Context, query *configs.Query, scale float64, dir string) error {
+	util.SetField("query", query.Name)
+	phase("gen")
+	util.LogInfo("------Prepare to generate data to %s------", dir)
+	tpchConfig := configs.NewTpchConfig(query, 0, scale)
+	// every table is generated in a single part
+	tpchConfig.Streams = make([]*configs.TableStream, 0)
+	kafkaExec := exec.NewQueryKafkaExecutor(tpchConfig)
+	err := kafkaExec.Prepare()
+	if err != nil {
+		return produceError(err)
+	}
+	return produceError(kafkaExec.WriteRowsToFiles(ctx, dir))
+}
+
+// RunTpchBackfill sends the full dataset of all tables before creating the mv,
+// and measures how long the mv takes to catch up to its final result
+func (b *Benchmark) RunTpchBackfill(ctx context.Context, query *configs.Query, scale float64) error {
+	util.SetField("query", query.Name)
+	phase("setup")
+	util.LogInfo("------Prepare to run tpch snapshot backfill------")
+	sqlConfig := configs.NewTpchSqlConfig(query)
+	tpchConfig := configs.NewTpchConfig(query, 0, scale)
+	// nothing is streamed, all tables are sent in batch
+	tpchConfig.Streams = make([]*configs.TableStream, 0)
+
+	expectedRows, expected, err := expectedResult(query)
+	if err != nil {
+		return err
+	}
 
 	// create all topics in Kafka
-	err := exec.AdminTopics("create")
+	err = exec.AdminTopics("create")
 	if err != nil {
-		util.LogErr(err.Error())
+		return setupError(err)
 	}
 
 	// prepare tpch data generator
-	tpchConfig := configs.NewTpchConfig(query, rate, scale)
 	kafkaExec := exec.NewQueryKafkaExecutor(tpchConfig)
 	err = kafkaExec.Prepare()
 	if err != nil {
-		util.LogErr(err.Error())
+		return produceError(err)
+	}
+
+	// create all source tables in RisingWave
+	err = b.createSources(ctx, sqlConfig)
+	if err != nil {
+		return setupError(err)
+	}
+
+	// send the full dataset
+	phase("batch")
+	start := time.Now()
+	err = kafkaExec.SendKafkaBatch(ctx)
+	if err != nil {
+		return produceError(err)
+	}
+	b.metricsManager.Record(metric.BatchSendSeconds, time.Now().Sub(start).Seconds())
+
+	// create mv related to a specific tpch query on top of the history
+	phase("setup")
+	start = time.Now()
+	err = b.createMVs(ctx, query, sqlConfig)
+	if err != nil {
+		return setupError(err)
+	}
+	b.metricsManager.Record(metric.MVCreateSeconds, time.Now().Sub(start).Seconds())
+
+	phase("catchup")
+	return b.waitCatchUp(ctx, query, start, expectedRows, expected)
+}
+
+// expectedResult expected row count (-1 if not expected) and result (empty if not expected) of the stable result,
+// flags override the header of the query
+func expectedResult(query *configs.Query) (int64, string, error) {
+	rows := query.ExpectedRows
+	path := query.ExpectedResult
+	if configs.BackfillExpectedRows >= 0 || configs.BackfillExpectedResult != "" {
+		rows = configs.BackfillExpectedRows
+		path = configs.BackfillExpectedResult
+	}
+	if path == "" {
+		return rows, "", nil
+	}
+	content, err := configs.ReadAsset(path)
+	if err != nil {
+		return rows, "", util.Errorf("read expected result error: %s", err.Error())
+	}
+	return rows, string(content), nil
+}
+
+// checkResult records whether the stable result matches the expectation of the query, if there is any,
+// and returns a ResultError if it does not
+func (b *Benchmark) checkResult(query *configs.Query, result string) error {
+	rows, expected, err := expectedResult(query)
+	if err != nil {
+		return err
+	}
+	if rows < 0 && expected == "" {
+		return nil
+	}
+	matched := true
+	if rows >= 0 {
+		matched = countRows(result) == rows
+	}
+	if expected != "" {
+		matched = matched && exec.SameResult(result, expected)
 	}
+	if matched {
+		b.metricsManager.Record(metric.ResultMatched, 1)
+		util.LogInfo("result of %s matches the expectation", query.Name)
+		return nil
+	}
+	b.metricsManager.Record(metric.ResultMatched, 0)
+	return resultError(util.Errorf("result of %s does not match the expectation", query.Name))
+}
+
+// resultDigest short hash of a result to tell samples apart without keeping them
+func resultDigest(result string) string {
+	sum := sha256.Sum256([]byte(result))
+	return hex.EncodeToString(sum[:8])
+}
 
-	kafkaExec.SendKafkaBatch()
-	kafkaExec.SendKafkaRealTime()
+func countRows(result string) int64 {
+	count := int64(0)
+	for _, line := range strings.Split(result, "\n") {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+	return count
 }
 
-func (b *Benchmark) RunTpchQuery(queryId int) {
+// waitCatchUp polls the mv until it matches the expected row count or result,
+// or until its result stays the same for configs.BackfillStableChecks polls if nothing is expected.
+// The time since `start` is recorded as the catch-up metric
+func (b *Benchmark) waitCatchUp(ctx context.Context, query *configs.Query, start time.Time, expectedRows int64, expected string) error {
+	util.LogInfo("------Wait for MV to catch up------")
+	executor := exec.NewSQLExecutor(b.db)
+	mv := query.ResultMV()
+	deadline := start.Add(configs.BackfillTimeout)
+	ticker := time.NewTicker(configs.ReadyPollInterval)
+	defer ticker.Stop()
+
+	lastResult := ""
+	stableChecks := 0
+	caughtUp := time.Time{}
+	for {
+		now := time.Now()
+		done := false
+		if expectedRows >= 0 {
+			count, err := executor.QueryCount(ctx, mv)
+			if err != nil {
+				return err
+			}
+			done = count == expectedRows
+			util.LogInfo("%s rows[%d/%d]", mv, count, expectedRows)
+		} else {
+			result, err := executor.QueryResult(ctx, fmt.Sprintf("select * from %s", mv))
+			if err != nil {
+				return err
+			}
+			if expected != "" {
+				done = exec.SameResult(result, expected)
+			} else {
+				// the result has stayed the same since it was first seen, an empty mv is not stable
+				if result == "" {
+					stableChecks = 0
+				} else {
+					if result != lastResult {
+						caughtUp = now
+						stableChecks = 0
+					}
+					stableChecks++
+				}
+				done = stableChecks >= configs.BackfillStableChecks
+				now = caughtUp
+			}
+			lastResult = result
+		}
+		if done {
+			elapsed := now.Sub(start)
+			b.metricsManager.Record(metric.CatchUpSeconds, elapsed.Seconds())
+			util.LogInfo("%s caught up in %f seconds", mv, elapsed.Seconds())
+			// only the row count is read if it is expected
+			if expectedRows < 0 {
+				util.LogInfo("---result---\n%s", lastResult)
+			}
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return resultError(util.Errorf("%s does not catch up within %v", mv, configs.BackfillTimeout))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunTpchQuery creates all topics, source tables and mvs of the query, nothing is sent
+func (b *Benchmark) RunTpchQuery(ctx context.Context, query *configs.Query) error {
+	util.SetField("query", query.Name)
+	phase("setup")
 	util.LogInfo("------Prepare to send tpch query to RisingWave------")
-	sqlConfig := configs.NewTpchSqlConfig(queryId)
+	sqlConfig := configs.NewTpchSqlConfig(query)
 
-	// create all source tables in RisingWave
-	util.LogInfo("------Create all source tables in RisingWave------")
-	paths, err := filepath.Glob(sqlConfig.SqlCreatePathPattern)
+	err := exec.AdminTopics("create")
 	if err != nil {
-		util.LogErr("parse sql create file path err: %s", err.Error())
+		return setupError(err)
 	}
-	err = b.runSQLFiles(paths, configs.SQLCreateSource)
+	err = b.createSources(ctx, sqlConfig)
 	if err != nil {
-		return
+		return setupError(err)
 	}
+	return setupError(b.createMVs(ctx, query, sqlConfig))
+}
 
-	// create mv related to a specific tpch query
-	util.LogInfo("------Create MV for q%d------", queryId)
-	paths, err = filepath.Glob(sqlConfig.SqlQueryPathPattern)
+// Verify checks the current result of the mv against the expectation of the query once
+func (b *Benchmark) Verify(ctx context.Context, query *configs.Query) error {
+	util.SetField("query", query.Name)
+	phase("verify")
+	mv := query.ResultMV()
+	if mv == "" {
+		return util.Errorf("%s creates no mv to verify", query.Name)
+	}
+	rows, expected, err := expectedResult(query)
+	if err != nil {
+		return err
+	}
+	if rows < 0 && expected == "" {
+		return util.Errorf("nothing is expected of %s, declare expected-rows or expected-result", query.Name)
+	}
+	executor := exec.NewSQLExecutor(b.db)
+	result, err := executor.QueryResult(ctx, fmt.Sprintf("select * from %s", mv))
+	if err != nil {
+		return err
+	}
+	util.LogInfo("---result---\n%s", result)
+	return b.checkResult(query, result)
+}
+
+// waitIngested polls row counts of tables until the expected rows are all visible,
+// the time it takes is recorded as the backfill metric.
+// Rows are counted by an mv per table, sources are not queryable
+func (b *Benchmark) waitIngested(ctx context.Context, expected map[configs.TpchTable]int64) error {
+	if !configs.ReadyWait || len(expected) == 0 {
+		return nil
+	}
+	util.LogInfo("------Wait for small tables to be ingested------")
+	executor := exec.NewSQLExecutor(b.db)
+	tables := make([]configs.TpchTable, 0)
+	for _, table := range configs.TpchAllTables {
+		if _, ok := expected[table]; ok {
+			tables = append(tables, table)
+		}
+	}
+	created := make([]configs.TpchTable, 0, len(tables))
+	defer func() {
+		b.dropReadyMVs(created)
+	}()
+	for _, table := range tables {
+		err := executor.ExecuteSQLStatement(ctx, fmt.Sprintf("create materialized view %s as select count(*) as cnt from %s", readyMV(table), table))
+		if err != nil {
+			return err
+		}
+		created = append(created, table)
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(configs.ReadyPollInterval)
+	defer ticker.Stop()
+	for {
+		ready := true
+		status := ""
+		for _, table := range tables {
+			rows := expected[table]
+			count, err := executor.QueryInt(ctx, fmt.Sprintf("select cnt from %s", readyMV(table)))
+			if err != nil {
+				return util.Errorf("count rows of %s error: %s", table, err.Error())
+			}
+			status += fmt.Sprintf(" %s[%d/%d]", table, count, rows)
+			if count < rows {
+				ready = false
+			}
+		}
+		if ready {
+			elapsed := time.Now().Sub(start)
+			b.metricsManager.Record(metric.BackfillSeconds, elapsed.Seconds())
+			util.LogInfo("small tables ingested in %f seconds:%s", elapsed.Seconds(), status)
+			return nil
+		}
+		if configs.ReadyTimeout > 0 && time.Now().Sub(start) > configs.ReadyTimeout {
+			return util.Errorf("small tables are not ingested within %v:%s", configs.ReadyTimeout, status)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// readyMV counts rows of a table sent in batch while waiting for them to be ingested
+func readyMV(table configs.TpchTable) string {
+	return fmt.Sprintf("tpch_ready_%s", table)
+}
+
+// dropReadyMVs drops ready mvs after the wait, the run may be interrupted, so it has its own deadline
+func (b *Benchmark) dropReadyMVs(tables []configs.TpchTable) {
+	ctx, cancel := context.WithTimeout(context.Background(), configs.CleanupTimeout)
+	defer cancel()
+	executor := exec.NewSQLExecutor(b.db)
+	for _, table := range tables {
+		err := executor.ExecuteSQLStatement(ctx, fmt.Sprintf("DROP MATERIALIZED VIEW %s", readyMV(table)))
+		if err != nil {
+			// a leftover ready mv fails creating mvs of the next run
+			util.LogWarn("no ready mv of %s is dropped: %s", table, err.Error())
+		}
+	}
+}
+
+// Interrupted the run is stopped by a signal, the report is partial
+func (b *Benchmark) Interrupted() {
+	b.metricsManager.MarkInterrupted()
+	if b.suite != nil {
+		b.suite.Interrupted = true
+	}
+}
+
+// WriteReport writes metrics and status of the run stopped by `err` to configs.ReportPath,
+// or reports of all queries if a suite is run
+func (b *Benchmark) WriteReport(err error) {
+	if b.suite != nil {
+		b.writeSuiteReport()
+		return
+	}
+	for _, name := range b.metricsManager.Names() {
+		value, _ := b.metricsManager.Get(name)
+		util.LogInfo("metric %s: %f", name, value)
+	}
+	if configs.ReportPath == "" {
+		return
+	}
+	report := b.metricsManager.Report()
+	report.Scenario = b.scenario
+	report.SetStatus(err)
+	err = report.Write(configs.ReportPath)
 	if err != nil {
-		util.LogErr("parse sql mv query file path err: %s", err.Error())
+		util.LogErr("write report error: %s", err.Error())
+		return
+	}
+	util.LogInfo("report is written to %s", configs.ReportPath)
+}
+
+func (b *Benchmark) writeSuiteReport() {
+	logSuite(b.suite)
+	if configs.ReportPath == "" {
+		return
 	}
-	err = b.runSQLFiles(paths, configs.SQLNormal)
+	err := b.suite.WriteReport(configs.ReportPath)
 	if err != nil {
+		util.LogErr("write report error: %s", err.Error())
 		return
 	}
+	util.LogInfo("report is written to %s", configs.ReportPath)
+}
+
+// ShowReport prints the summary of a report, a ResultError is returned if any query does not pass
+func ShowReport(path string) error {
+	suite, err := metric.ReadReport(path)
+	if err != nil {
+		return util.Errorf("read report error: %s", err.Error())
+	}
+	logSuite(suite)
+	if !suite.Passed() {
+		return resultError(util.Errorf("not all queries in %s pass", path))
+	}
+	return nil
+}
+
+func logSuite(suite *metric.SuiteReport) {
+	util.LogInfo("------Suite summary------")
+	for _, query := range suite.Queries {
+		if query.Report == nil {
+			util.LogInfo("%s: %s", query.Query, query.Status)
+			continue
+		}
+		util.LogInfo("%s: %s produce[%.0f rows/s] convergence[%.3fs] freshness p99[%.3fs]", query.Query, query.Status,
+			query.Report.Metrics[metric.ProduceRate], query.Report.Metrics[metric.ConvergeSeconds],
+			query.Report.Metrics[metric.Freshness+"_p99_seconds"])
+		if query.Error != "" {
+			util.LogInfo("  %s", query.Error)
+		}
+	}
+}
+
+// phase marks following log lines with the phase of the run
+func phase(name string) {
+	util.SetField("phase", name)
 }
 
 // Call SQLExecutor to send SQL to frontend
-func (b *Benchmark) runSQLFiles(paths []string, typ configs.SQLStmtType) error {
+func (b *Benchmark) runSQLFiles(ctx context.Context, paths []string, typ configs.SQLStmtType) error {
 	executor := exec.NewSQLExecutor(b.db)
 	for _, path := range paths {
-		s := util.ReadFile(path)
-		e := executor.ExecuteSQLFile(s, path, typ)
+		s, err := configs.ScanAsset(path)
+		if err != nil {
+			return err
+		}
+		e := executor.ExecuteSQLFile(ctx, s, path, typ)
 		return e
 	}
 	return nil
 }
 
-func (b *Benchmark) checkResults(queryId int) error {
-	if configs.CheckMVInterval != -1 && queryId >= 1 && queryId <= 20 {
-		timer := time.NewTicker(time.Duration(configs.CheckMVInterval) * time.Second)
-		for {
-			select {
-			case <-timer.C:
-				executor := exec.NewSQLExecutor(b.db)
-				err, re := executor.ExecuteSQLQuery(fmt.Sprintf("select * from tpch_q%d", queryId))
-				if err != nil {
-					timer.Stop()
-					return err
-				}
-				util.LogInfo("---result---\n%s", re)
+// startProbes creates the probe mv and sends probes until the returned function is called,
+// which waits for pending probes and records freshness percentiles
+func (b *Benchmark) startProbes(ctx context.Context) (func(), error) {
+	if configs.ProbeInterval <= 0 {
+		return func() {}, nil
+	}
+	util.LogInfo("------Create MV for freshness probes------")
+	executor := exec.NewSQLExecutor(b.db)
+	err := executor.ExecuteSQLStatement(ctx, exec.ProbeMVStatement())
+	if err != nil {
+		return nil, util.Errorf("create probe mv error: %s", err.Error())
+	}
+	prober, err := exec.NewProber(b.db)
+	if err != nil {
+		return nil, util.Errorf("connect to kafka error: %s", err.Error())
+	}
+	done := make(chan struct{})
+	probed := make(chan *exec.ProbeStats, 1)
+	go func() {
+		probed <- prober.Run(ctx, done)
+	}()
+	return func() {
+		close(done)
+		stats := <-probed
+		b.metricsManager.Record(metric.ProbesSent, float64(stats.Sent))
+		b.metricsManager.Record(metric.ProbesLost, float64(stats.Lost))
+		b.metricsManager.RecordLatencies(metric.Freshness, stats.Latencies)
+		util.LogInfo("freshness: %d probes sent, %d lost", stats.Sent, stats.Lost)
+	}, nil
+}
+
+// startSink creates a kafka sink from the mv and consumes its output topic until the returned function is called,
+// which waits for remaining output changes and records latency correlated with flushes of input batches
+func (b *Benchmark) startSink(ctx context.Context, query *configs.Query, kafkaExec *exec.QueryKafkaExecutor) (func(), error) {
+	mv := query.ResultMV()
+	if !configs.SinkEnabled || mv == "" {
+		return func() {}, nil
+	}
+	util.LogInfo("------Create sink for %s------", mv)
+	topic := exec.SinkName(mv)
+	err := exec.AdminSinkTopic("create", topic)
+	if err != nil {
+		return nil, err
+	}
+	executor := exec.NewSQLExecutor(b.db)
+	err = executor.ExecuteSQLStatement(ctx, exec.SinkStatement(mv))
+	if err != nil {
+		return nil, util.Errorf("create sink error: %s", err.Error())
+	}
+	consumer, err := exec.NewSinkConsumer(topic)
+	if err != nil {
+		return nil, util.Errorf("connect to kafka error: %s", err.Error())
+	}
+	timeline := exec.NewInputTimeline()
+	kafkaExec.TrackInputTimeline(timeline)
+	done := make(chan struct{})
+	consumed := make(chan struct{})
+	go func() {
+		consumer.Run(ctx, done)
+		close(consumed)
+	}()
+	return func() {
+		close(done)
+		<-consumed
+		stats := consumer.Stats(timeline)
+		b.metricsManager.Record(metric.SinkChanges, float64(stats.Changes))
+		b.metricsManager.Record(metric.SinkUnmatched, float64(stats.Unmatched))
+		b.metricsManager.RecordLatencies(metric.SinkLatency, stats.Latencies)
+		if span := stats.LastOutput.Sub(stats.FirstOutput).Seconds(); span > 0 {
+			b.metricsManager.Record(metric.SinkChangeRate, float64(stats.Changes)/span)
+		}
+		if flushes := timeline.Flushes(); len(flushes) > 0 && stats.LastOutput.After(flushes[len(flushes)-1]) {
+			b.metricsManager.Record(metric.SinkFinalLatency, stats.LastOutput.Sub(flushes[len(flushes)-1]).Seconds())
+		}
+		util.LogInfo("sink: %d output changes, %d input batches cause no output change", stats.Changes, stats.Unmatched)
+	}, nil
+}
+
+// sampleResults samples the mv every configs.CheckMVInterval seconds and keeps all samples in the report.
+// Once production finishes, i.e. `produced` receives the time of the last produced row,
+// sampling stops when configs.SampleStableChecks consecutive results are the same,
+// and the time from the last produced row to the first of them is recorded as the convergence latency.
+// The row count of every sample is passed to `observe`
+func (b *Benchmark) sampleResults(ctx context.Context, query *configs.Query, produced <-chan time.Time, observe func(rows int64)) error {
+	mv := query.ResultMV()
+	if configs.CheckMVInterval == -1 || mv == "" || !query.Sample {
+		return nil
+	}
+	executor := exec.NewSQLExecutor(b.db)
+	sql := fmt.Sprintf("select * from %s", mv)
+	ticker := time.NewTicker(time.Duration(configs.CheckMVInterval) * time.Second)
+	defer ticker.Stop()
+
+	var producedAt time.Time
+	var timeout <-chan time.Time
+	lastDigest := ""
+	stableChecks := 0
+	var stableSince time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case producedAt = <-produced:
+			produced = nil
+			timeout = time.After(configs.ConvergeTimeout)
+		case <-timeout:
+			return resultError(util.Errorf("result of %s is not stable within %v after production finishes", mv, configs.ConvergeTimeout))
+		case now := <-ticker.C:
+			result, err := executor.QueryResult(ctx, sql)
+			if err != nil {
+				return err
+			}
+			rows, digest := countRows(result), resultDigest(result)
+			b.metricsManager.AddSample(now, rows, digest)
+			observe(rows)
+			util.LogInfo("result of %s: %d rows, digest %s", mv, rows, digest)
+			if producedAt.IsZero() {
+				continue
+			}
+			if stableChecks == 0 || digest != lastDigest {
+				stableChecks = 0
+				stableSince = now
+			}
+			lastDigest = digest
+			stableChecks++
+			if stableChecks >= configs.SampleStableChecks {
+				latency := stableSince.Sub(producedAt)
+				b.metricsManager.Record(metric.ConvergeSeconds, latency.Seconds())
+				util.LogInfo("result of %s converges %f seconds after production finishes", mv, latency.Seconds())
+				return b.checkResult(query, result)
 			}
 		}
 	}
-	return nil
 }
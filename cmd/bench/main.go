@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	_ "github.com/lib/pq"
@@ -9,15 +11,25 @@ import (
 	"github.com/singularity-data/tpch-bench/pkg/configs"
 	"github.com/singularity-data/tpch-bench/pkg/exec"
 	"github.com/singularity-data/tpch-bench/pkg/util"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 var (
-	benchType            string // "tpch-std", "tpch-event"
+	scenarioPath         string
+	backfill             bool   // query: snapshot backfill instead of streaming
+	outDir               string // gen: directory of generated files
 	qps                  int
-	producerQps          int     // qps for single thread producer
-	query                int     // only "tpch-std" need
-	dataScale            float64 // only "tpch-std" need
-	frontendIp           string  // RisingWave frontend addr
+	producerQps          int    // qps for single thread producer
+	queryName            string // tpch query id or name of a query file in queryDir
+	queryDir             string
+	assetsDir            string // custom queries and ddl overriding the embedded ones
+	querySelection       string // queries run as a suite, ex: 1,3,5-10 or all
+	dataScale            float64
+	frontendIp           string // RisingWave frontend addr
 	frontendPort         string
 	kafkaAddress         string
 	kafkaPartition       int // 3 by default
@@ -26,38 +38,313 @@ var (
 	postgresDBPwd        string
 	enableLegacyFrontend bool
 	samplingInterval     int //
+	enableEventTime      bool
+	eventTimeSpeedup     float64
+	disorderFraction     float64
+	disorderMaxDelay     time.Duration
+	lateFraction         float64
+	lateDelay            time.Duration
+	shuffleWindow        int
+	keySkews             string
+	skewTopK             int
+	driftSchedule        string
+	distributionPath     string
+	seedOffset           int64
+	textPoolMB           int
+	textPoolCacheDir     string
+	streamDuration       time.Duration
+	unbounded            bool
+	causalOrders         bool
+	causalMaxSkew        int64
+	streamDeclaration    string
+	readyTimeout         time.Duration
+	noReadyWait          bool
+	reportPath           string
+	expectedRows         int64
+	expectedResult       string
+	backfillTimeout      time.Duration
+	flushTimeout         time.Duration
+	cleanupOnInterrupt   bool
+	stableChecks         int
+	convergeTimeout      time.Duration
+	probeInterval        time.Duration
+	probePollInterval    time.Duration
+	probeTimeout         time.Duration
+	sinkEnabled          bool
+	sinkIdleTimeout      time.Duration
+	logLevel             string
+	logFormat            string
+	logFile              string
+	progressInterval     time.Duration
+	dryRun               bool
 )
 
 func init() {
-	flag.StringVar(&benchType, "type", "", "determine content of benchmark")
+	flag.StringVar(&scenarioPath, "scenario", "", "json file declaring the run by sections, flags set on the command line override it")
+	flag.BoolVar(&backfill, "backfill", false, "query: send the full dataset before creating the mv, and measure how long the mv takes to catch up")
+	flag.StringVar(&outDir, "out", "./data", "gen: directory of generated json files")
 	flag.IntVar(&qps, "qps", 300000, "benchmark qps")
 	flag.IntVar(&producerQps, "producer", 80000, "")
-	flag.IntVar(&query, "query", -1, "tpch query id")
+	flag.StringVar(&queryName, "query", "-1", "tpch query id, or name of a query file in --query-dir, -1 for all tables without mv")
+	flag.StringVar(&querySelection, "queries", "", "query: run queries as a suite one after another, ex: 1,3,5-10 or all, overrides --query")
+	flag.StringVar(&queryDir, "query-dir", configs.QueryDir, "directory that query files are discovered from")
+	flag.StringVar(&assetsDir, "assets", "", "directory of custom queries and ddl, its files override the embedded ones of the same name, ex: q3.sql, create_v2.sql")
 	flag.Float64Var(&dataScale, "scale", 1.0, "dataset scale of tpch")
 	flag.StringVar(&frontendIp, "frontend", "localhost", "")
 	flag.StringVar(&frontendPort, "frontend-port", "4566", "")
 	flag.StringVar(&kafkaAddress, "kafka-addr", "localhost:9092", "")
 	flag.IntVar(&kafkaPartition, "partition", 4, "kafka partition numbers per topic")
-	flag.StringVar(&postgresDBName, "db-name", "postgres", "db name")
-	flag.StringVar(&postgresDBUser, "user", "postgres", "db username")
-	flag.StringVar(&postgresDBPwd, "pwd", "postgres", "db password")
+	flag.StringVar(&postgresDBName, "db-name", "dev", "db name")
+	flag.StringVar(&postgresDBUser, "user", "root", "db username")
+	flag.StringVar(&postgresDBPwd, "pwd", "", "db password")
 	flag.BoolVar(&enableLegacyFrontend, "legacy-frontend", false, "")
 	flag.IntVar(&samplingInterval, "i", -1, "interval that view results of the query")
-	flag.Parse()
+	flag.BoolVar(&enableEventTime, "event-time", false, "add event-time columns to orders and lineitem")
+	flag.Float64Var(&eventTimeSpeedup, "event-speedup", 1.0, "seconds of event time per second of wall clock")
+	flag.Float64Var(&disorderFraction, "disorder-fraction", 0, "fraction of realtime rows delayed by a random time")
+	flag.DurationVar(&disorderMaxDelay, "disorder-max-delay", time.Second, "max delay of disordered rows")
+	flag.Float64Var(&lateFraction, "late-fraction", 0, "fraction of realtime rows delayed beyond the watermark")
+	flag.DurationVar(&lateDelay, "late-delay", time.Minute, "delay of late rows")
+	flag.IntVar(&shuffleWindow, "shuffle-window", 0, "shuffle realtime rows within windows of this size")
+	flag.StringVar(&keySkews, "skew", "", "skewed foreign keys, ex: l_partkey=zipf:1.1,o_custkey=hotspot:0.01:0.9")
+	flag.IntVar(&skewTopK, "skew-topk", 10, "number of most frequent keys reported for skewed columns")
+	flag.StringVar(&distributionPath, "dists", configs.TpchDistributionPath, "distribution file used as the seed of data generators")
+	flag.Int64Var(&seedOffset, "seed-offset", 0, "offset of all generator seeds, different offsets give different datasets")
+	flag.DurationVar(&streamDuration, "duration", 0, "stop realtime producers after this duration, orders and lineitem keep generating beyond the scale")
+	flag.BoolVar(&unbounded, "unbounded", false, "realtime orders and lineitem never run out of rows")
+	flag.StringVar(&streamDeclaration, "streams", "", "tables sent in realtime and their rate ratios, ex: customer:1,orders:10,lineitem:40")
+	flag.BoolVar(&causalOrders, "causal", false, "send each order before its lineitems")
+	flag.Int64Var(&causalMaxSkew, "causal-max-skew", 10000, "max orders that orders could run ahead of lineitem in causal mode")
+	flag.IntVar(&textPoolMB, "textpool-mb", 300, "MiB of text that comments are picked from")
+	flag.StringVar(&textPoolCacheDir, "textpool-cache", "", "directory to save and load the text pool, no cache if empty")
+	flag.StringVar(&driftSchedule, "drift", "", "change distributions while streaming, ex: 30s:smode:AIR=5,TRUCK=1;2m:o_oprio:1-URGENT=10")
+	flag.DurationVar(&readyTimeout, "ready-timeout", 5*time.Minute, "max time to wait for small tables to be ingested before streaming, no timeout if 0")
+	flag.BoolVar(&noReadyWait, "no-ready-wait", false, "start streaming right after small tables are sent, without waiting for them to be ingested")
+	flag.StringVar(&reportPath, "report", "", "write metrics of the run to this json file")
+	flag.Int64Var(&expectedRows, "expected-rows", -1, "row count of the stable mv result, overrides the query header")
+	flag.StringVar(&expectedResult, "expected-result", "", "file of the stable mv result, overrides the query header")
+	flag.DurationVar(&backfillTimeout, "backfill-timeout", 30*time.Minute, "query --backfill: max time to wait for the mv to catch up")
+	flag.DurationVar(&flushTimeout, "flush-timeout", 10*time.Second, "max time for producers to deliver produced rows")
+	flag.BoolVar(&cleanupOnInterrupt, "cleanup", false, "drop mv, source tables and topics when interrupted by SIGINT or SIGTERM")
+	flag.IntVar(&stableChecks, "stable-checks", 3, "sampling stops once this many consecutive results are the same after production finishes")
+	flag.DurationVar(&convergeTimeout, "converge-timeout", 30*time.Minute, "max time to sample after production finishes")
+	flag.DurationVar(&probeInterval, "probe-interval", 0, "send a probe row every interval to measure freshness, no probe if 0")
+	flag.DurationVar(&probePollInterval, "probe-poll", 100*time.Millisecond, "interval of polling the probe mv")
+	flag.DurationVar(&probeTimeout, "probe-timeout", time.Minute, "max time to wait for probes after production finishes")
+	flag.BoolVar(&sinkEnabled, "sink", false, "create a kafka sink from the mv and measure latency of its output changes")
+	flag.DurationVar(&sinkIdleTimeout, "sink-idle", 30*time.Second, "stop consuming the sink once no output change is received for this duration after production finishes")
+	flag.StringVar(&logLevel, "log-level", "info", "debug, info, warn or error, per-batch lines of producers are debug logs")
+	flag.StringVar(&logFormat, "log-format", util.LogText, "text or json")
+	flag.StringVar(&logFile, "log-file", "", "append logs to this file besides stdout")
+	flag.DurationVar(&progressInterval, "progress", 10*time.Second, "interval of progress lines aggregated across producers, no progress line if 0")
+	flag.BoolVar(&dryRun, "dry-run", false, "gen, produce, setup and query: print tables, row counts, producers, topics, ddl and mvs of the run without connecting to kafka or the frontend")
+}
+
+// exit codes, so that CI could tell why a run fails
+const (
+	exitOK          = 0
+	exitError       = 1 // invalid usage, configuration or other errors
+	exitSetup       = 2 // topics, source tables or mvs could not be created, or tables are not ingested in time
+	exitProduce     = 3 // rows could not be generated or delivered to kafka
+	exitResult      = 4 // the result does not match the expectation, or never becomes stable
+	exitInterrupted = 130
+)
+
+type command struct {
+	name     string
+	summary  string
+	run      func(ctx context.Context, benchmark *tpchbench.Benchmark, query *configs.Query) error
+	measured bool // a report is written after the run, and --cleanup applies when it's interrupted
+}
+
+var commands = []*command{
+	{"gen", "generate rows of tables of --query as json files in --out, nothing is sent to kafka", runGen, false},
+	{"produce", "create all topics and send rows of tables of --query to kafka, tables in --streams are sent in realtime after the others", runProduce, true},
+	{"setup", "create all topics, source tables and mvs of --query, nothing is sent", runSetup, true},
+	{"query", "run the benchmark of --query: set up, send small tables, create mvs, stream and sample the result until it converges.\n" +
+		"--queries runs a suite of queries one by one, --backfill sends the full dataset before creating the mv", runQuery, true},
+	{"verify", "check the current result of the mv of --query against the expectation of the query", runVerify, false},
+	{"clean", "drop the sink, mvs and source tables of --query, and delete all topics", runClean, false},
+	{"report", "print the summary of a report, `bench report <file>` or --report, it fails if any query does not pass", runReport, false},
+	{"doctor", "check that kafka and the frontend are reachable, no topic, mv or source is left by a previous run,\n" +
+		"files of --query are readable from the working directory and memory is enough for the text pool\n" +
+		"(read from /proc/meminfo, only a warning on macOS)", runDoctor, false},
 }
 
 func main() {
-	// dataSourceName := fmt.Sprintf("host=localhost port=%d user=%s password=%s dbname=%s sslmode=disable",
-	// postgresDBPort, postgresDBUser, postgresDBPwd, postgresDBName)
+	os.Exit(run(os.Args[1:]))
+}
 
-	if enableLegacyFrontend {
-		configs.SqlCreatePath = "./assets/data/create.sql"
-		frontendPort = "4567"
+func run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return exitError
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		if len(args) > 1 {
+			if cmd := findCommand(args[1]); cmd != nil {
+				commandUsage(cmd)
+				return exitOK
+			}
+		}
+		usage()
+		return exitOK
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		util.LogErr("unknown command: %s", args[0])
+		usage()
+		return exitError
+	}
+
+	flag.CommandLine.Init("bench "+cmd.name, flag.ContinueOnError)
+	flag.CommandLine.Usage = func() {
+		commandUsage(cmd)
+	}
+	if err := flag.CommandLine.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitError
+	}
+	closeLog, err := configure()
+	if err != nil {
+		util.LogErr(err.Error())
+		return exitError
+	}
+	defer closeLog()
+	query, err := configs.FindQuery(queryName)
+	if err != nil {
+		util.LogErr(err.Error())
+		return exitError
+	}
+	if dryRun {
+		err = runDryRun(cmd, query)
+		if err != nil {
+			util.LogErr(err.Error())
+		}
+		return exitCode(err, false)
 	}
-	dataSourceName := fmt.Sprintf("host=%s port=%s dbname=dev sslmode=disable", frontendIp, frontendPort)
-	db, err := sql.Open("postgres", dataSourceName)
+	db, err := openDB()
 	if err != nil {
 		util.LogErr("db open failed, %s", err.Error())
+		return exitError
+	}
+	defer db.Close()
+
+	// producers stop and flush, sampling stops, and a partial report is written on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	benchmark := tpchbench.NewBenchmark(db)
+	benchmark.SetScenario(configs.ResolveScenario(flag.CommandLine))
+	err = cmd.run(ctx, benchmark, query)
+
+	interrupted := ctx.Err() != nil
+	// a second signal kills the process
+	stop()
+	if interrupted {
+		util.LogInfo("------Benchmark is interrupted------")
+		benchmark.Interrupted()
+	}
+	if cmd.measured {
+		benchmark.WriteReport(err)
+	}
+	// queries of a suite are cleaned up by the suite
+	if interrupted && configs.CleanupOnInterrupt && cmd.measured && querySelection == "" {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), configs.CleanupTimeout)
+		if err := benchmark.CleanTpchAll(cleanupCtx, query); err != nil {
+			util.LogErr("clean error: %s", err.Error())
+		}
+		cancel()
+	}
+	if err != nil && !interrupted {
+		util.LogErr(err.Error())
+	}
+	return exitCode(err, interrupted)
+}
+
+func exitCode(err error, interrupted bool) int {
+	var setupErr *tpchbench.SetupError
+	var produceErr *tpchbench.ProduceError
+	var resultErr *tpchbench.ResultError
+	switch {
+	case interrupted:
+		return exitInterrupted
+	case err == nil:
+		return exitOK
+	case errors.As(err, &setupErr):
+		return exitSetup
+	case errors.As(err, &produceErr):
+		return exitProduce
+	case errors.As(err, &resultErr):
+		return exitResult
+	default:
+		return exitError
+	}
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "usage: bench <command> [flags]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-8s %s\n", cmd.name, strings.SplitN(cmd.summary, "\n", 2)[0])
+	}
+	fmt.Fprintf(out, "\nrun `bench help <command>` or `bench <command> -h` for flags of a command\n")
+	fmt.Fprintf(out, "\nexit codes: 0 ok, 1 error, 2 setup failure, 3 production failure, 4 result mismatch, 130 interrupted\n")
+}
+
+func commandUsage(cmd *command) {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "usage: bench %s [flags]\n\n%s\n\nflags:\n", cmd.name, cmd.summary)
+	flag.PrintDefaults()
+}
+
+// configure applies the scenario file and flags to configs and logging,
+// the returned function closes the log file
+func configure() (func(), error) {
+	closeLog := func() {}
+	// flags not set on the command line take values from the scenario file
+	if scenarioPath != "" {
+		scenario, err := configs.LoadScenario(scenarioPath)
+		if err != nil {
+			return closeLog, err
+		}
+		err = scenario.Apply(flag.CommandLine)
+		if err != nil {
+			return closeLog, err
+		}
+	}
+
+	// logging
+	level, err := util.ParseLevel(logLevel)
+	if err != nil {
+		return closeLog, err
+	}
+	util.SetLogLevel(level)
+	err = util.SetLogFormat(logFormat)
+	if err != nil {
+		return closeLog, err
+	}
+	if logFile != "" {
+		closeLog, err = util.SetLogFile(logFile)
+		if err != nil {
+			return func() {}, err
+		}
+	}
+
+	if enableLegacyFrontend {
+		configs.SqlCreatePath = "./assets/data/create.sql"
+		frontendPort = "4567"
 	}
 
 	// [debug] qps for single thread producer
@@ -70,22 +357,184 @@ func main() {
 	configs.KafkaAddrForFrontend = kafkaAddress
 	// kafka partition numbers per topic
 	configs.KafkaPartition = kafkaPartition
+	configs.FlushTimeout = flushTimeout
+	configs.ProgressInterval = progressInterval
 
 	configs.CheckMVInterval = samplingInterval
+	configs.SampleStableChecks = stableChecks
+	configs.ConvergeTimeout = convergeTimeout
 
-	benchmark := tpchbench.NewBenchmark(db)
-	switch benchType {
-	case "tpch-std":
-		benchmark.RunTpchStd(query, qps, dataScale)
-	case "tpch-clean":
-		benchmark.CleanTpchAll(query)
-	case "tpch-k":
-		benchmark.RunSendKafka(query, qps, dataScale)
-	case "tpch-q":
-		benchmark.RunTpchQuery(query)
+	// freshness probes
+	configs.ProbeInterval = probeInterval
+	configs.ProbePollInterval = probePollInterval
+	configs.ProbeTimeout = probeTimeout
+
+	// sink round trip
+	configs.SinkEnabled = sinkEnabled
+	configs.SinkIdleTimeout = sinkIdleTimeout
+
+	// readiness gate and report
+	configs.ReadyTimeout = readyTimeout
+	configs.ReadyWait = !noReadyWait
+	configs.ReportPath = reportPath
+	configs.CleanupOnInterrupt = cleanupOnInterrupt
+
+	// snapshot backfill
+	configs.BackfillExpectedRows = expectedRows
+	configs.BackfillExpectedResult = expectedResult
+	configs.BackfillTimeout = backfillTimeout
+
+	// soak test beyond the scale factor
+	configs.StreamDuration = streamDuration
+	configs.Unbounded = unbounded
+
+	// tables sent in realtime
+	configs.StreamDeclaration, err = configs.ParseTableStreams(streamDeclaration)
+	if err != nil {
+		return closeLog, err
+	}
+
+	// arrival order of orders and lineitem
+	configs.CausalOrders = causalOrders
+	configs.CausalMaxSkew = causalMaxSkew
+
+	// event time of orders and lineitem rows
+	configs.EventTimeEnabled = enableEventTime
+	configs.EventTimeSpeedup = eventTimeSpeedup
+
+	// out-of-order and late events
+	configs.DisorderFraction = disorderFraction
+	configs.DisorderMaxDelay = disorderMaxDelay
+	configs.LateFraction = lateFraction
+	configs.LateDelay = lateDelay
+	configs.ShuffleWindow = shuffleWindow
+	if configs.CausalOrders && configs.DisorderEnabled() {
+		return closeLog, util.Errorf("--causal could not be combined with --disorder-fraction, --late-fraction or --shuffle-window, disorder reorders rows after the causal gate")
+	}
+	if configs.DisorderEnabled() && !configs.EventTimeEnabled {
+		util.LogInfo("rows are sent out of order without --event-time, disorder is only visible in kafka offsets")
+	}
+
+	// seeds of data generators
+	configs.TpchDistributionPath = distributionPath
+	configs.SeedOffset = seedOffset
+	configs.TextPoolSize = textPoolMB * 1024 * 1024
+	configs.TextPoolCacheDir = textPoolCacheDir
+
+	// skewed foreign keys
+	configs.KeySkews, err = configs.ParseKeySkews(keySkews)
+	if err != nil {
+		return closeLog, err
+	}
+	configs.SkewTopK = skewTopK
+
+	// distributions drifting while streaming
+	configs.DriftSchedule, err = configs.ParseDriftSchedule(driftSchedule)
+	if err != nil {
+		return closeLog, err
+	}
+
+	// query files, and custom queries and ddl besides the embedded ones
+	configs.QueryDir = queryDir
+	if assetsDir != "" {
+		if info, err := os.Stat(assetsDir); err != nil || !info.IsDir() {
+			return closeLog, util.Errorf("--assets %s is not a directory", assetsDir)
+		}
+	}
+	configs.AssetsDir = assetsDir
+	return closeLog, nil
+}
+
+func openDB() (*sql.DB, error) {
+	dataSourceName := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable",
+		frontendIp, frontendPort, postgresDBUser, postgresDBName)
+	if postgresDBPwd != "" {
+		// quotes and backslashes are escaped in quoted values of the dsn
+		dataSourceName += fmt.Sprintf(" password='%s'", strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(postgresDBPwd))
+	}
+	return sql.Open("postgres", dataSourceName)
+}
+
+// runDryRun prints the plan of the command, nothing is connected
+func runDryRun(cmd *command, query *configs.Query) error {
+	name := cmd.name
+	queries := []*configs.Query{query}
+	switch cmd.name {
+	case "gen", "produce", "setup":
+	case "query":
+		if backfill {
+			name = "backfill"
+		}
+		if querySelection != "" {
+			if backfill {
+				return util.Errorf("--queries does not apply to --backfill")
+			}
+			suite, err := configs.ParseQuerySelection(querySelection)
+			if err != nil {
+				return err
+			}
+			queries = suite
+		}
 	default:
-		util.LogErr("undefined benchmark type: %s", benchType)
+		return util.Errorf("--dry-run does not apply to %s", cmd.name)
+	}
+	for _, query := range queries {
+		err := tpchbench.DryRun(os.Stdout, name, query, qps, dataScale)
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+func runGen(ctx context.Context, benchmark *tpchbench.Benchmark, query *configs.Query) error {
+	return benchmark.RunGenerate(ctx, query, dataScale, outDir)
+}
+
+func runProduce(ctx context.Context, benchmark *tpchbench.Benchmark, query *configs.Query) error {
+	return benchmark.RunSendKafka(ctx, query, qps, dataScale)
+}
+
+func runSetup(ctx context.Context, benchmark *tpchbench.Benchmark, query *configs.Query) error {
+	return benchmark.RunTpchQuery(ctx, query)
+}
+
+func runQuery(ctx context.Context, benchmark *tpchbench.Benchmark, query *configs.Query) error {
+	if querySelection != "" {
+		if backfill {
+			return util.Errorf("--queries does not apply to --backfill")
+		}
+		suite, err := configs.ParseQuerySelection(querySelection)
+		if err != nil {
+			return err
+		}
+		return benchmark.RunSuite(ctx, suite, qps, dataScale)
+	}
+	if backfill {
+		return benchmark.RunTpchBackfill(ctx, query, dataScale)
+	}
+	return benchmark.RunTpchStd(ctx, query, qps, dataScale)
+}
+
+func runVerify(ctx context.Context, benchmark *tpchbench.Benchmark, query *configs.Query) error {
+	return benchmark.Verify(ctx, query)
+}
+
+func runClean(ctx context.Context, benchmark *tpchbench.Benchmark, query *configs.Query) error {
+	return benchmark.CleanTpchAll(ctx, query)
+}
+
+func runReport(ctx context.Context, benchmark *tpchbench.Benchmark, query *configs.Query) error {
+	path := flag.Arg(0)
+	if path == "" {
+		path = configs.ReportPath
+	}
+	if path == "" {
+		return util.Errorf("no report to show, run `bench report <file>`")
+	}
+	return tpchbench.ShowReport(path)
+}
 
-	_ = db.Close()
+func runDoctor(ctx context.Context, benchmark *tpchbench.Benchmark, query *configs.Query) error {
+	return benchmark.Doctor(ctx, os.Stdout, query)
 }
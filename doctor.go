@@ -0,0 +1,218 @@
+package tpch_bench
+
+import (
+	"context"
+	"fmt"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/exec"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DoctorCheck result of a check, warnings do not fail the doctor
+type DoctorCheck struct {
+	Name   string
+	Detail string
+	Err    error
+	Warn   bool
+}
+
+// Doctor checks kafka, the frontend, state left by previous runs, assets of the query and memory before a run.
+// Every check is printed, and it fails if any check fails
+func (b *Benchmark) Doctor(ctx context.Context, w io.Writer, query *configs.Query) error {
+	checks := make([]*DoctorCheck, 0)
+	topics, kafkaCheck := checkKafka()
+	checks = append(checks, kafkaCheck)
+	if kafkaCheck.Err == nil {
+		checks = append(checks, CheckLeftoverTopics(topics))
+	}
+	frontendCheck := b.checkFrontend(ctx)
+	checks = append(checks, frontendCheck)
+	if frontendCheck.Err == nil {
+		checks = append(checks, b.checkLeftoverRelations(ctx))
+	}
+	checks = append(checks, CheckAssets(query), CheckMemory())
+
+	failures := 0
+	for _, check := range checks {
+		switch {
+		case check.Err != nil && check.Warn:
+			fmt.Fprintf(w, "[warn] %-9s %s\n", check.Name, check.Err.Error())
+		case check.Err != nil:
+			fmt.Fprintf(w, "[fail] %-9s %s\n", check.Name, check.Err.Error())
+			failures++
+		default:
+			fmt.Fprintf(w, "[ok]   %-9s %s\n", check.Name, check.Detail)
+		}
+	}
+	if failures > 0 {
+		return util.Errorf("%d of %d checks fail", failures, len(checks))
+	}
+	return nil
+}
+
+// checkKafka brokers are reachable through the admin client
+func checkKafka() ([]string, *DoctorCheck) {
+	check := &DoctorCheck{Name: "kafka"}
+	topics, err := exec.ListTopics(configs.DoctorTimeout)
+	if err != nil {
+		check.Err = err
+		return nil, check
+	}
+	check.Detail = fmt.Sprintf("%s is reachable, topics: %s", configs.KafkaAddr, joinOrNone(topics))
+	return topics, check
+}
+
+// CheckLeftoverTopics topics of tables still hold rows of a previous run, new sources would read them again
+func CheckLeftoverTopics(topics []string) *DoctorCheck {
+	check := &DoctorCheck{Name: "topics"}
+	tables := make(map[string]bool)
+	for _, table := range configs.TpchAllTables {
+		tables[string(table)] = true
+	}
+	sinks := make(map[string]bool)
+	for mv := range DeclaredMVs() {
+		sinks[exec.SinkName(mv)] = true
+	}
+	leftovers := make([]string, 0)
+	for _, topic := range topics {
+		if tables[topic] || sinks[topic] {
+			leftovers = append(leftovers, topic)
+		}
+	}
+	if len(leftovers) > 0 {
+		check.Err = util.Errorf("left by a previous run: %s, run `bench clean`", strings.Join(leftovers, ", "))
+		return check
+	}
+	check.Detail = "no topic is left by a previous run"
+	return check
+}
+
+// checkFrontend the frontend answers through the configured dsn
+func (b *Benchmark) checkFrontend(ctx context.Context) *DoctorCheck {
+	check := &DoctorCheck{Name: "frontend"}
+	ctx, cancel := context.WithTimeout(ctx, configs.DoctorTimeout)
+	defer cancel()
+	version, err := exec.NewSQLExecutor(b.db).QueryResult(ctx, "select version()")
+	if err != nil {
+		check.Err = util.Errorf("select version() error: %s", err.Error())
+		return check
+	}
+	check.Detail = version
+	return check
+}
+
+// checkLeftoverRelations mvs and sources of a previous run make creating them fail
+func (b *Benchmark) checkLeftoverRelations(ctx context.Context) *DoctorCheck {
+	check := &DoctorCheck{Name: "state"}
+	ctx, cancel := context.WithTimeout(ctx, configs.DoctorTimeout)
+	defer cancel()
+	executor := exec.NewSQLExecutor(b.db)
+
+	// mvs of the bench are named tpch_<query> by default, others are declared in query files
+	benchMVs := DeclaredMVs()
+	mvs, err := executor.QueryNames(ctx, "show materialized views")
+	if err != nil {
+		check.Err = util.Errorf("show materialized views error: %s", err.Error())
+		return check
+	}
+	leftovers := make([]string, 0)
+	for _, mv := range mvs {
+		if strings.HasPrefix(mv, "tpch_") || benchMVs[mv] {
+			leftovers = append(leftovers, "mv "+mv)
+		}
+	}
+
+	sources, err := executor.QueryNames(ctx, "show sources")
+	if err != nil {
+		check.Err = util.Errorf("show sources error: %s", err.Error())
+		return check
+	}
+	tables := make(map[string]bool)
+	for _, table := range configs.TpchAllTables {
+		tables[string(table)] = true
+	}
+	for _, source := range sources {
+		if tables[source] {
+			leftovers = append(leftovers, "source "+source)
+		}
+	}
+
+	if len(leftovers) > 0 {
+		check.Err = util.Errorf("left by a previous run: %s, run `bench clean --query <query>`", strings.Join(leftovers, ", "))
+		return check
+	}
+	check.Detail = "no mv or source is left by a previous run"
+	return check
+}
+
+// CheckAssets files read by a run of the query are readable, from --assets, the working directory or the embedded ones
+func CheckAssets(query *configs.Query) *DoctorCheck {
+	check := &DoctorCheck{Name: "assets"}
+	cwd, _ := os.Getwd()
+	sqlConfig := configs.NewTpchSqlConfig(query)
+	paths := []string{configs.TpchDistributionPath, sqlConfig.SqlCreatePathPattern, sqlConfig.SqlDropPathPattern}
+	if query.Path != "" {
+		paths = append(paths, query.Path)
+	}
+	for _, path := range paths {
+		if _, err := configs.ReadAsset(path); err != nil {
+			check.Err = util.Errorf("%s is not readable from %s: %s", path, cwd, err.Error())
+			return check
+		}
+	}
+	queries, err := configs.DiscoverQueries(configs.QueryDir)
+	if err != nil {
+		check.Err = util.Errorf("discover queries in %s error: %s", configs.QueryDir, err.Error())
+		return check
+	}
+	check.Detail = fmt.Sprintf("%s are readable, %d queries in %s", strings.Join(paths, ", "), len(queries), filepath.Clean(configs.QueryDir))
+	if configs.AssetsDir != "" {
+		check.Detail += fmt.Sprintf(", overridden by %s", configs.AssetsDir)
+	}
+	return check
+}
+
+// CheckMemory the text pool is built in memory before any row is generated
+func CheckMemory() *DoctorCheck {
+	check := &DoctorCheck{Name: "memory"}
+	available, err := util.AvailableMemory()
+	if err != nil {
+		check.Err = util.Errorf("available memory is unknown: %s", err.Error())
+		check.Warn = true
+		return check
+	}
+	if available < int64(configs.TextPoolSize) {
+		check.Err = util.Errorf("%d MiB available, less than the text pool of %d MiB, lower --textpool-mb",
+			available>>20, configs.TextPoolSize>>20)
+		return check
+	}
+	check.Detail = fmt.Sprintf("%d MiB available, text pool %d MiB", available>>20, configs.TextPoolSize>>20)
+	return check
+}
+
+// DeclaredMVs mvs declared by query files, and the probe mv
+func DeclaredMVs() map[string]bool {
+	mvs := map[string]bool{exec.ProbeMV: true}
+	queries, err := configs.DiscoverQueries(configs.QueryDir)
+	if err != nil {
+		// reported by the assets check
+		return mvs
+	}
+	for _, query := range queries {
+		for _, mv := range query.MVs {
+			mvs[mv] = true
+		}
+	}
+	return mvs
+}
+
+func joinOrNone(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,125 @@
+package tpch_bench
+
+import (
+	"fmt"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/exec"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// DryRun prints everything the command would do with the query, without connecting to kafka or the frontend.
+// Commands are gen, produce, setup, query and backfill
+func DryRun(w io.Writer, command string, query *configs.Query, rate int, scale float64) error {
+	tpchConfig := configs.NewTpchConfig(query, rate, scale)
+	if command == "gen" || command == "backfill" {
+		// every table is sent or generated in batch
+		tpchConfig.Streams = make([]*configs.TableStream, 0)
+	}
+	produces := command != "setup"
+	creates := command == "setup" || command == "query" || command == "backfill"
+
+	fmt.Fprintf(w, "------Plan of %s for %s------\n", command, query.Name)
+	tables := make([]string, 0)
+	for _, table := range tpchConfig.Tables {
+		tables = append(tables, string(table))
+	}
+	fmt.Fprintf(w, "tables: %s\n", strings.Join(tables, ", "))
+	fmt.Fprintf(w, "main table: %s\n", tpchConfig.MainTable)
+
+	if produces {
+		plan, err := exec.NewQueryKafkaExecutor(tpchConfig).Plan()
+		if err != nil {
+			return produceError(err)
+		}
+		printProducers(w, plan)
+		if command == "produce" || command == "query" {
+			printDuration(w, plan.Duration)
+		}
+	}
+
+	if command != "gen" {
+		topics := exec.Topics()
+		if configs.SinkEnabled && command == "query" && query.ResultMV() != "" {
+			topics = append(topics, exec.SinkName(query.ResultMV()))
+		}
+		fmt.Fprintf(w, "topics to create: %s\n", strings.Join(topics, ", "))
+	}
+
+	if creates {
+		sqlConfig := configs.NewTpchSqlConfig(query)
+		fmt.Fprintf(w, "source tables:\n")
+		err := printSQLFiles(w, sqlConfig.SqlCreatePathPattern, configs.SQLCreateSource)
+		if err != nil {
+			return setupError(err)
+		}
+		if query.Path == "" {
+			fmt.Fprintf(w, "mvs: none\n")
+			return nil
+		}
+		fmt.Fprintf(w, "mvs:\n")
+		err = printSQLFiles(w, sqlConfig.SqlQueryPathPattern, configs.SQLNormal)
+		if err != nil {
+			return setupError(err)
+		}
+	}
+	return nil
+}
+
+func printProducers(w io.Writer, plan *exec.RunPlan) {
+	fmt.Fprintf(w, "rows:\n")
+	total := 0
+	for _, producer := range plan.Producers {
+		total += producer.Nums
+		rows := fmt.Sprint(producer.Rows)
+		if producer.Rows == math.MaxInt64 {
+			rows = "unbounded"
+		}
+		if producer.Type == configs.RealTime {
+			fmt.Fprintf(w, "  %-9s %12s  realtime producers[%d] rate[%d rows/s each]\n", producer.Table, rows, producer.Nums, producer.Rate)
+		} else {
+			fmt.Fprintf(w, "  %-9s %12s  batch producers[%d]\n", producer.Table, rows, producer.Nums)
+		}
+	}
+	fmt.Fprintf(w, "producers: %d\n", total)
+}
+
+func printDuration(w io.Writer, duration time.Duration) {
+	switch {
+	case duration < 0:
+		fmt.Fprintf(w, "expected duration: unbounded, until interrupted\n")
+	case duration == 0:
+		fmt.Fprintf(w, "expected duration: nothing is streamed, tables are sent in batch as fast as kafka accepts\n")
+	default:
+		fmt.Fprintf(w, "expected duration: %v of realtime production\n", duration.Round(time.Second))
+	}
+}
+
+// printSQLFiles statements of files matching the pattern as they would be executed
+func printSQLFiles(w io.Writer, pattern string, typ configs.SQLStmtType) error {
+	paths, err := configs.GlobAssets(pattern)
+	if err != nil {
+		return util.Errorf("parse sql file path err: %s", err.Error())
+	}
+	if len(paths) == 0 {
+		return util.Errorf("no sql file matches %s", pattern)
+	}
+	for _, path := range paths {
+		scanner, err := configs.ScanAsset(path)
+		if err != nil {
+			return err
+		}
+		stmts, err := exec.RenderSQLFile(scanner, path, typ)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "  -- %s\n", path)
+		for _, stmt := range stmts {
+			fmt.Fprintf(w, "  %s;\n", strings.TrimSuffix(stmt.SQL(), ";"))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package tpch_bench
+
+// SetupError topics, source tables or mvs could not be created, or tables are not ingested in time
+type SetupError struct {
+	Err error
+}
+
+func (e *SetupError) Error() string {
+	return "setup: " + e.Err.Error()
+}
+
+func (e *SetupError) Unwrap() error {
+	return e.Err
+}
+
+// ProduceError rows could not be generated or delivered to kafka
+type ProduceError struct {
+	Err error
+}
+
+func (e *ProduceError) Error() string {
+	return "produce: " + e.Err.Error()
+}
+
+func (e *ProduceError) Unwrap() error {
+	return e.Err
+}
+
+// ResultError the result does not match the expectation, or never becomes stable
+type ResultError struct {
+	Err error
+}
+
+func (e *ResultError) Error() string {
+	return "result: " + e.Err.Error()
+}
+
+func (e *ResultError) Unwrap() error {
+	return e.Err
+}
+
+func setupError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &SetupError{err}
+}
+
+func produceError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ProduceError{err}
+}
+
+func resultError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ResultError{err}
+}
@@ -0,0 +1,113 @@
+package configs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/singularity-data/tpch-bench/assets"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AssetsDir directory of custom queries and ddl, its files override the embedded ones of the same name
+var AssetsDir string
+
+// assetsRoot default paths of assets are relative to the root of the repo, ex: ./assets/data/q3.sql
+const assetsRoot = "assets/data"
+
+// assetName name of a default path relative to assetsRoot, false for other paths
+func assetName(p string) (string, bool) {
+	if filepath.IsAbs(p) {
+		return "", false
+	}
+	name, err := filepath.Rel(assetsRoot, filepath.Clean(p))
+	if err != nil || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return name, true
+}
+
+// ReadAsset reads a file. Default paths under ./assets/data are looked up in AssetsDir first,
+// then in the working directory, and at last in the files embedded in the binary.
+// Other paths are read from the file system only
+func ReadAsset(p string) ([]byte, error) {
+	name, ok := assetName(p)
+	if !ok {
+		return os.ReadFile(p)
+	}
+	if AssetsDir != "" {
+		content, err := os.ReadFile(filepath.Join(AssetsDir, name))
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return content, err
+		}
+	}
+	content, err := os.ReadFile(p)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return content, err
+	}
+	embedded, embedErr := assets.FS.ReadFile(path.Join("data", filepath.ToSlash(name)))
+	if embedErr != nil {
+		// the path is reported as given
+		return nil, err
+	}
+	return embedded, nil
+}
+
+// ScanAsset scans lines of a file read by ReadAsset
+func ScanAsset(p string) (*bufio.Scanner, error) {
+	content, err := ReadAsset(p)
+	if err != nil {
+		return nil, err
+	}
+	return bufio.NewScanner(bytes.NewReader(content)), nil
+}
+
+// GlobAssets paths matching the pattern in the same places that ReadAsset looks up, sorted without duplicates
+func GlobAssets(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	name, ok := assetName(pattern)
+	if !ok {
+		return matches, nil
+	}
+
+	paths := make([]string, 0, len(matches))
+	seen := make(map[string]bool)
+	add := func(p string) {
+		p = filepath.Clean(p)
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	for _, match := range matches {
+		add(match)
+	}
+	if AssetsDir != "" {
+		custom, err := filepath.Glob(filepath.Join(AssetsDir, name))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range custom {
+			rel, err := filepath.Rel(AssetsDir, match)
+			if err == nil {
+				add(filepath.Join(assetsRoot, rel))
+			}
+		}
+	}
+	embedded, err := fs.Glob(assets.FS, path.Join("data", filepath.ToSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range embedded {
+		add(filepath.Join(assetsRoot, filepath.FromSlash(strings.TrimPrefix(match, "data/"))))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
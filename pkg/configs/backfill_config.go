@@ -0,0 +1,16 @@
+package configs
+
+import "time"
+
+// BackfillExpectedRows the mv has caught up once it has this many rows, not checked if negative
+var BackfillExpectedRows int64 = -1
+
+// BackfillExpectedResult file of the expected mv result, whitespace is ignored when comparing, not checked if empty
+var BackfillExpectedResult string
+
+// BackfillStableChecks without expected rows or result, the mv has caught up once
+// its result stays the same for this many polls
+var BackfillStableChecks = 3
+
+// BackfillTimeout max time to wait for the mv to catch up
+var BackfillTimeout = 30 * time.Minute
@@ -0,0 +1,60 @@
+package configs
+
+import (
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DriftPoint at offset `At` since realtime streaming starts,
+// weights of the listed terms of a distribution are replaced, other terms keep their weights
+type DriftPoint struct {
+	At           time.Duration
+	Distribution string
+	Weights      map[string]int
+}
+
+// DriftSchedule sorted by offset, empty if distributions never change
+var DriftSchedule = make([]*DriftPoint, 0)
+
+// ParseDriftSchedule parses specs like "30s:smode:AIR=5,TRUCK=1;2m:o_oprio:1-URGENT=10",
+// points are separated by ';'
+func ParseDriftSchedule(s string) ([]*DriftPoint, error) {
+	points := make([]*DriftPoint, 0)
+	if strings.TrimSpace(s) == "" {
+		return points, nil
+	}
+	for _, item := range strings.Split(s, ";") {
+		words := strings.SplitN(strings.TrimSpace(item), ":", 3)
+		if len(words) != 3 {
+			return nil, util.Errorf("drift spec format error, expected: offset:distribution:term=weight,..., found: %s", item)
+		}
+		at, err := time.ParseDuration(words[0])
+		if err != nil {
+			return nil, util.Errorf("drift spec format error, %s", err.Error())
+		}
+		point := &DriftPoint{
+			at,
+			words[1],
+			make(map[string]int),
+		}
+		for _, tw := range strings.Split(words[2], ",") {
+			kv := strings.SplitN(tw, "=", 2)
+			if len(kv) != 2 {
+				return nil, util.Errorf("drift spec format error, expected: term=weight, found: %s", tw)
+			}
+			weight, err := strconv.Atoi(kv[1])
+			if err != nil || weight < 0 {
+				return nil, util.Errorf("drift spec format error, invalid weight of %s: %s", kv[0], kv[1])
+			}
+			point.Weights[kv[0]] = weight
+		}
+		points = append(points, point)
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].At < points[j].At
+	})
+	return points, nil
+}
@@ -0,0 +1,24 @@
+package configs
+
+import "time"
+
+// EventTimeEnabled adds an event-time column to orders and lineitem rows,
+// and sets the timestamp of kafka messages to the same value
+var EventTimeEnabled bool
+
+// EventTimeSpeedup is how many seconds of event time pass in one second of wall clock
+var EventTimeSpeedup = 1.0
+
+// out-of-order and late events of realtime producers
+var (
+	DisorderFraction float64       // fraction of rows delayed by a random time within DisorderMaxDelay
+	DisorderMaxDelay time.Duration // upper bound of the delay of disordered rows
+	LateFraction     float64       // fraction of rows delayed by LateDelay, beyond the watermark of the MV
+	LateDelay        time.Duration // should be larger than the watermark delay of the MV
+	ShuffleWindow    int           // rows are shuffled within windows of this size, disabled if <= 1
+)
+
+// DisorderEnabled whether realtime producers send rows out of order
+func DisorderEnabled() bool {
+	return DisorderFraction > 0 || LateFraction > 0 || ShuffleWindow > 1
+}
@@ -1,5 +1,7 @@
 package configs
 
+import "time"
+
 var KafkaAddr string
 var KafkaAddrForFrontend string
 var KafkaPartition int
@@ -9,9 +11,42 @@ const (
 	Batch    string = "batch"    // producer send all events at one stroke
 )
 
+// StreamDuration realtime producers stop after this duration instead of when the dataset is exhausted,
+// orders and lineitem keep generating beyond the scale factor. Disabled if 0
+var StreamDuration time.Duration
+
+// Unbounded realtime orders and lineitem never run out of rows, producers stop at StreamDuration if it's set
+var Unbounded bool
+
+// CausalOrders each order is sent before its lineitems, orders and lineitem use the same number of producers
+var CausalOrders bool
+
+// CausalMaxSkew max orders that the order stream could run ahead of the lineitem stream in causal mode
+var CausalMaxSkew int64 = 10000
+
+// FlushTimeout max time a producer waits for produced rows to be delivered,
+// rows not delivered in time are reported, ex: when the benchmark is interrupted
+var FlushTimeout = 10 * time.Second
+
+// SinkEnabled a kafka sink is created from the mv of the query, its changelog is consumed to measure latency
+var SinkEnabled bool
+
+// SinkIdleTimeout after production finishes, the sink consumer stops once no output change is received for this duration
+var SinkIdleTimeout = 30 * time.Second
+
+func UnboundedStream() bool {
+	return Unbounded || StreamDuration > 0
+}
+
 type KafkaProducerConfig struct {
 	Nums  int
 	Rate  int
 	Table TpchTable
 	Type  string
 }
+
+// ProducerLogInterval a producer logs its progress in debug logs at most once per interval
+var ProducerLogInterval = 10 * time.Second
+
+// ProgressInterval interval of progress lines aggregated across producers, no progress line if 0
+var ProgressInterval = 10 * time.Second
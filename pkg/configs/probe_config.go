@@ -0,0 +1,12 @@
+package configs
+
+import "time"
+
+// ProbeInterval interval of sending probe rows to measure freshness, no probe if 0
+var ProbeInterval time.Duration
+
+// ProbePollInterval interval of polling the probe mv, it bounds the resolution of freshness
+var ProbePollInterval = 100 * time.Millisecond
+
+// ProbeTimeout after production finishes, probes not visible within this time are counted as lost
+var ProbeTimeout = time.Minute
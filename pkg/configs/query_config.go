@@ -0,0 +1,336 @@
+package configs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// QueryDir directory that queries are discovered from
+var QueryDir = "./assets/data"
+
+// AllTablesQuery name of the pseudo query involving all tables without any mv, ex: to send all data to kafka
+const AllTablesQuery string = "all"
+
+// Query a query file and its metadata declared in the header, ex:
+//
+//	# tables: customer, orders, lineitem
+//	# main: lineitem
+//	# mv: tpch_q3
+//	# result: tpch_q3
+//	# streams: orders:1,lineitem:4
+//	# expected-rows: 10
+//	# expected-result: q3.expected
+//	# sample: true
+//	statement
+//	create materialized view tpch_q3 as ...
+//
+// The header is the leading block of `# key: value` lines, all keys are optional
+type Query struct {
+	Name           string         // file name without extension, ex: q3
+	Path           string         // empty for AllTablesQuery
+	Tables         []TpchTable    // all tables by default
+	MainTable      TpchTable      // lineitem by default
+	MVs            []string       // mvs created by the file in order, tpch_<name> by default
+	Result         string         // mv holding the result, one of MVs, the last one if not declared
+	Streams        []*TableStream // tables sent in realtime and their ratios, empty if not declared
+	ExpectedRows   int64          // row count of the stable result, -1 if not declared
+	ExpectedResult string         // file of the stable result, relative paths are resolved against the query file
+	Sample         bool           // whether the result is sampled while streaming, false for mvs too large to read, true by default
+}
+
+func newAllTablesQuery() *Query {
+	return &Query{
+		Name:         AllTablesQuery,
+		Tables:       TpchAllTables,
+		MainTable:    LineItem,
+		MVs:          make([]string, 0),
+		Streams:      make([]*TableStream, 0),
+		ExpectedRows: -1,
+		Sample:       true,
+	}
+}
+
+// ResultMV the mv holding the result of the query, empty if the query creates no mv.
+// Later mvs may depend on earlier ones, so the last one holds the result unless declared
+func (q *Query) ResultMV() string {
+	if q.Result != "" {
+		return q.Result
+	}
+	if len(q.MVs) == 0 {
+		return ""
+	}
+	return q.MVs[len(q.MVs)-1]
+}
+
+// LoadQuery parses the header of a query file
+func LoadQuery(path string) (*Query, error) {
+	content, err := ReadAsset(path)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	query := newAllTablesQuery()
+	query.Name = name
+	query.Path = path
+	query.MVs = []string{"tpch_" + name}
+	mainDeclared := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		kv := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(line, "#")), ":", 2)
+		if len(kv) != 2 {
+			// plain comment
+			continue
+		}
+		key := strings.TrimSpace(kv[0])
+		value := strings.TrimSpace(kv[1])
+		switch key {
+		case "tables":
+			query.Tables = make([]TpchTable, 0)
+			for _, word := range strings.Split(value, ",") {
+				table := TpchTable(strings.TrimSpace(word))
+				if !IsTpchTable(table) {
+					return nil, util.Errorf("%s:%d: unknown table: %s", path, lineNum, table)
+				}
+				query.Tables = append(query.Tables, table)
+			}
+		case "main":
+			query.MainTable = TpchTable(value)
+			mainDeclared = true
+		case "mv":
+			query.MVs = make([]string, 0)
+			for _, word := range strings.Split(value, ",") {
+				query.MVs = append(query.MVs, strings.TrimSpace(word))
+			}
+		case "result":
+			query.Result = value
+		case "sample":
+			query.Sample, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, util.Errorf("%s:%d: invalid sample: %s", path, lineNum, value)
+			}
+		case "streams":
+			query.Streams, err = ParseTableStreams(value)
+			if err != nil {
+				return nil, util.Errorf("%s:%d: %s", path, lineNum, err.Error())
+			}
+		case "expected-rows":
+			query.ExpectedRows, err = strconv.ParseInt(value, 10, 64)
+			if err != nil || query.ExpectedRows < 0 {
+				return nil, util.Errorf("%s:%d: invalid expected rows: %s", path, lineNum, value)
+			}
+		case "expected-result":
+			query.ExpectedResult = value
+			if !filepath.IsAbs(value) {
+				query.ExpectedResult = filepath.Join(filepath.Dir(path), value)
+			}
+		default:
+			return nil, util.Errorf("%s:%d: unknown header key: %s", path, lineNum, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !mainDeclared && !query.involve(query.MainTable) {
+		query.MainTable = query.Tables[0]
+	}
+	if !query.involve(query.MainTable) {
+		return nil, util.Errorf("%s: main table %s is not in tables", path, query.MainTable)
+	}
+	for _, stream := range query.Streams {
+		if !query.involve(stream.Table) {
+			return nil, util.Errorf("%s: streamed table %s is not in tables", path, stream.Table)
+		}
+	}
+	if query.Result != "" && !query.creates(query.Result) {
+		return nil, util.Errorf("%s: result mv %s is not in mvs", path, query.Result)
+	}
+	return query, nil
+}
+
+func (q *Query) involve(table TpchTable) bool {
+	for _, t := range q.Tables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *Query) creates(mv string) bool {
+	for _, m := range q.MVs {
+		if m == mv {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscoverQueries loads all query files in a directory, i.e. sql files with a header.
+// Queries are sorted by name, tpch queries (q<id>) by id first
+func DiscoverQueries(dir string) ([]*Query, error) {
+	paths, err := GlobAssets(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	queries := make([]*Query, 0)
+	for _, path := range paths {
+		if !hasHeader(path) {
+			continue
+		}
+		query, err := LoadQuery(path)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, query)
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		idI, okI := TpchQueryId(queries[i].Name)
+		idJ, okJ := TpchQueryId(queries[j].Name)
+		if okI && okJ {
+			return idI < idJ
+		}
+		if okI != okJ {
+			return okI
+		}
+		return queries[i].Name < queries[j].Name
+	})
+	return queries, nil
+}
+
+// TpchQueryId id of names like q3
+func TpchQueryId(name string) (int, bool) {
+	if !strings.HasPrefix(name, "q") {
+		return 0, false
+	}
+	id, err := strconv.Atoi(name[1:])
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// hasHeader whether the leading comments of a file contain any `# key: value` line
+func hasHeader(path string) bool {
+	content, err := ReadAsset(path)
+	if err != nil {
+		return false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			return false
+		}
+		if strings.Contains(line, ":") {
+			return true
+		}
+	}
+	return false
+}
+
+// FindQuery looks up a query in QueryDir by name, ids like "3" stand for "q3",
+// "-1" or empty stands for AllTablesQuery
+func FindQuery(name string) (*Query, error) {
+	if name == "" || name == "-1" || name == AllTablesQuery {
+		return newAllTablesQuery(), nil
+	}
+	if _, err := strconv.Atoi(name); err == nil {
+		name = "q" + name
+	}
+	queries, err := DiscoverQueries(QueryDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, query := range queries {
+		if query.Name == name {
+			return query, nil
+		}
+	}
+	return nil, util.Errorf("query %s is not found in %s", name, QueryDir)
+}
+
+// ParseQuerySelection selects queries in QueryDir in order, ex: "1,3,5-10", or "all" for every discovered query
+// except those not sampled, which are too large to run in a suite. Items are query ids, id ranges or query names,
+// every selected query must exist
+func ParseQuerySelection(spec string) ([]*Query, error) {
+	queries, err := DiscoverQueries(QueryDir)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(spec) == AllTablesQuery {
+		sampled := make([]*Query, 0, len(queries))
+		for _, query := range queries {
+			if query.Sample {
+				sampled = append(sampled, query)
+			}
+		}
+		return sampled, nil
+	}
+	byName := make(map[string]*Query)
+	for _, query := range queries {
+		byName[query.Name] = query
+	}
+
+	selected := make([]*Query, 0)
+	seen := make(map[string]bool)
+	add := func(name string) error {
+		query, ok := byName[name]
+		if !ok {
+			return util.Errorf("query %s is not found in %s", name, QueryDir)
+		}
+		if !seen[name] {
+			seen[name] = true
+			selected = append(selected, query)
+		}
+		return nil
+	}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, util.Errorf("empty item in query selection: %s", spec)
+		}
+		if bounds := strings.SplitN(item, "-", 2); len(bounds) == 2 {
+			from, errFrom := strconv.Atoi(bounds[0])
+			to, errTo := strconv.Atoi(bounds[1])
+			if errFrom == nil && errTo == nil {
+				if from > to {
+					return nil, util.Errorf("invalid query range: %s", item)
+				}
+				for id := from; id <= to; id++ {
+					if err := add(fmt.Sprintf("q%d", id)); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+		}
+		if _, err := strconv.Atoi(item); err == nil {
+			item = "q" + item
+		}
+		if err := add(item); err != nil {
+			return nil, err
+		}
+	}
+	return selected, nil
+}
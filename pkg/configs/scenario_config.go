@@ -0,0 +1,167 @@
+package configs
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ScenarioSections flags that could be declared in each section of a scenario file, ex:
+//
+//	{
+//	  "target": {"frontend": "10.0.0.1", "kafka-addr": "10.0.0.2:9092"},
+//	  "workload": {"queries": ["1", "3", "5-10"], "scale": 10, "qps": 500000},
+//	  "phases": {"i": 5, "converge-timeout": "10m"},
+//	  "assertions": {"expected-rows": 10},
+//	  "output": {"report": "report.json"}
+//	}
+var ScenarioSections = map[string][]string{
+	"target": {"frontend", "frontend-port", "db-name", "user", "pwd", "legacy-frontend", "kafka-addr", "partition"},
+	"workload": {"query", "queries", "backfill", "out", "query-dir", "assets", "scale", "qps", "producer", "streams",
+		"skew", "skew-topk", "drift", "dists", "seed-offset", "textpool-mb", "textpool-cache",
+		"event-time", "event-speedup", "disorder-fraction", "disorder-max-delay", "late-fraction", "late-delay",
+		"shuffle-window", "causal", "causal-max-skew", "duration", "unbounded"},
+	"phases": {"ready-timeout", "no-ready-wait", "i", "stable-checks", "converge-timeout", "backfill-timeout", "flush-timeout",
+		"probe-interval", "probe-poll", "probe-timeout", "sink", "sink-idle", "cleanup"},
+	"assertions": {"expected-rows", "expected-result"},
+	"output":     {"report", "log-level", "log-format", "log-file", "progress"},
+}
+
+// secretFlags these flags are left out of the resolved scenario, they are passed by flag or a scenario file of the user
+var secretFlags = map[string]bool{
+	"pwd": true,
+}
+
+// Scenario flag values declared in a scenario file by section
+type Scenario map[string]map[string]string
+
+// LoadScenario parses a json scenario file, unknown sections or keys are rejected
+func LoadScenario(path string) (Scenario, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sections map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(content, &sections); err != nil {
+		return nil, util.Errorf("%s: %s", path, err.Error())
+	}
+
+	scenario := make(Scenario)
+	for section, values := range sections {
+		allowed, ok := ScenarioSections[section]
+		if !ok {
+			return nil, util.Errorf("%s: unknown section: %s", path, section)
+		}
+		scenario[section] = make(map[string]string)
+		for key, raw := range values {
+			if !contains(allowed, key) {
+				return nil, util.Errorf("%s: unknown key in %s: %s", path, section, key)
+			}
+			value, err := scenarioValue(raw)
+			if err != nil {
+				return nil, util.Errorf("%s: %s.%s: %s", path, section, key, err.Error())
+			}
+			scenario[section][key] = value
+		}
+	}
+	return scenario, nil
+}
+
+// scenarioValue flag value of a json scalar, arrays of scalars are joined with ","
+func scenarioValue(raw json.RawMessage) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", err
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool, float64:
+		// keep the literal, ex: 100000 instead of 1e+05
+		return string(bytes.TrimSpace(raw)), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case string, bool, float64:
+				items = append(items, fmt.Sprint(item))
+			default:
+				return "", util.Errorf("arrays could only hold strings, numbers or booleans")
+			}
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", util.Errorf("value should be a string, number, boolean or array")
+	}
+}
+
+// Apply sets flags declared in the scenario, flags set on the command line override the scenario
+func (s Scenario) Apply(flags *flag.FlagSet) error {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, section := range sortedKeys(s) {
+		values := s[section]
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if set[key] {
+				continue
+			}
+			if flags.Lookup(key) == nil {
+				return util.Errorf("scenario %s.%s: flag is not defined", section, key)
+			}
+			if err := flags.Set(key, values[key]); err != nil {
+				return util.Errorf("scenario %s.%s: %s", section, key, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// ResolveScenario final values of all flags in scenario sections except secrets.
+// Written as a scenario file, it reproduces the run once secrets are passed by flag
+func ResolveScenario(flags *flag.FlagSet) Scenario {
+	scenario := make(Scenario)
+	for section, keys := range ScenarioSections {
+		scenario[section] = make(map[string]string)
+		for _, key := range keys {
+			f := flags.Lookup(key)
+			if f == nil {
+				continue
+			}
+			if secretFlags[key] {
+				continue
+			}
+			scenario[section][key] = f.Value.String()
+		}
+	}
+	return scenario
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(s Scenario) []string {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+package configs
+
+import (
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"strconv"
+	"strings"
+)
+
+type SkewKind string
+
+const (
+	Zipf    SkewKind = "zipf"    // zipf:<exponent>
+	Hotspot SkewKind = "hotspot" // hotspot:<fraction of hot keys>:<fraction of rows hitting hot keys>
+)
+
+// foreign key columns whose distribution could be skewed
+const (
+	SkewLPartKey  string = "l_partkey"
+	SkewLSuppKey  string = "l_suppkey"
+	SkewOCustKey  string = "o_custkey"
+	SkewPSPartKey string = "ps_partkey"
+)
+
+type SkewSpec struct {
+	Kind     SkewKind
+	Exponent float64 // zipf
+	HotKeys  float64 // hotspot
+	HotRows  float64 // hotspot
+}
+
+// KeySkews skewed columns, keys of other columns are uniform
+var KeySkews = map[string]*SkewSpec{}
+
+// SkewTopK number of most frequent keys reported for each skewed column
+var SkewTopK = 10
+
+// ParseKeySkews parses specs like "l_partkey=zipf:1.1,o_custkey=hotspot:0.01:0.9"
+func ParseKeySkews(s string) (map[string]*SkewSpec, error) {
+	skews := make(map[string]*SkewSpec)
+	if strings.TrimSpace(s) == "" {
+		return skews, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			return nil, util.Errorf("skew spec format error, expected: column=kind:params, found: %s", item)
+		}
+		switch kv[0] {
+		case SkewLPartKey, SkewLSuppKey, SkewOCustKey, SkewPSPartKey:
+		default:
+			return nil, util.Errorf("column %s could not be skewed", kv[0])
+		}
+		spec, err := parseSkewSpec(kv[1])
+		if err != nil {
+			return nil, util.Errorf("skew spec of %s error, %s", kv[0], err.Error())
+		}
+		skews[kv[0]] = spec
+	}
+	return skews, nil
+}
+
+func parseSkewSpec(s string) (*SkewSpec, error) {
+	words := strings.Split(s, ":")
+	params := make([]float64, 0, len(words)-1)
+	for _, w := range words[1:] {
+		p, err := strconv.ParseFloat(w, 64)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, p)
+	}
+	switch SkewKind(words[0]) {
+	case Zipf:
+		if len(params) != 1 || params[0] <= 0 {
+			return nil, util.Errorf("expected zipf:<exponent>, exponent > 0, found: %s", s)
+		}
+		return &SkewSpec{Kind: Zipf, Exponent: params[0]}, nil
+	case Hotspot:
+		if len(params) != 2 || params[0] <= 0 || params[0] >= 1 || params[1] <= 0 || params[1] >= 1 {
+			return nil, util.Errorf("expected hotspot:<hot keys>:<hot rows>, both within (0, 1), found: %s", s)
+		}
+		return &SkewSpec{Kind: Hotspot, HotKeys: params[0], HotRows: params[1]}, nil
+	default:
+		return nil, util.Errorf("unknown skew kind: %s", words[0])
+	}
+}
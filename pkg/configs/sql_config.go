@@ -1,7 +1,5 @@
 package configs
 
-import "fmt"
-
 const (
 	LineItemSqlFilePath string = "./assets/data/ingest.sql"
 	LineItemTblFilePath string = "./assets/data/lineitem.tbl"
@@ -24,11 +22,11 @@ type SqlConfig struct {
 	SqlDropPathPattern   string
 }
 
-func NewTpchSqlConfig(queryId int) *SqlConfig {
+func NewTpchSqlConfig(query *Query) *SqlConfig {
 	return &SqlConfig{
 		SqlCreatePathPattern: SqlCreatePath,
 		SqlIngestPathPattern: "",
-		SqlQueryPathPattern:  fmt.Sprintf("./assets/data/q%d.sql", queryId),
+		SqlQueryPathPattern:  query.Path,
 		SqlCheckPathPattern:  "",
 		SqlDropPathPattern:   "./assets/data/drop.sql",
 	}
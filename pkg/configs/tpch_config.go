@@ -1,82 +1,37 @@
 package configs
 
 import (
-	"fmt"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"strconv"
+	"strings"
 )
 
 type TpchTable string
 
 const (
-	TpchDistributionPath string    = "./assets/data/dists.dss"
-	LineItem             TpchTable = "lineitem"
-	Orders               TpchTable = "orders"
-	Customer             TpchTable = "customer"
-	Supplier             TpchTable = "supplier"
-	Part                 TpchTable = "part"
-	PartSupp             TpchTable = "partsupp"
-	Nation               TpchTable = "nation"
-	Region               TpchTable = "region"
+	LineItem TpchTable = "lineitem"
+	Orders   TpchTable = "orders"
+	Customer TpchTable = "customer"
+	Supplier TpchTable = "supplier"
+	Part     TpchTable = "part"
+	PartSupp TpchTable = "partsupp"
+	Nation   TpchTable = "nation"
+	Region   TpchTable = "region"
 )
 
-// change here to run self-defined query
-// you can remain these unchanged, and it will still work
+// TpchDistributionPath distributions used as the seed of data generators
+var TpchDistributionPath = "./assets/data/dists.dss"
 
-// data of main table will be generated in real time,
-// usually main table is the largest table in one query
-var selfDefineMainTable = LineItem
-var selfDefineTables = []TpchTable{
-	Part, Supplier, LineItem, Orders, Customer, Nation, Region, PartSupp,
-}
+// SeedOffset deterministically perturbs seeds of all generators,
+// different offsets give different datasets at the same scale
+var SeedOffset int64
 
-var tpchQueryTableMap = map[int][]TpchTable{
-	-1: {Part, Supplier, LineItem, Orders, Customer, Nation, Region, PartSupp},
-	1:  {LineItem},
-	2:  {Part, Supplier, PartSupp, Nation, Region},
-	3:  {Customer, Orders, LineItem},
-	4:  {LineItem, Orders},
-	5:  {Customer, Orders, LineItem, Supplier, Nation, Region},
-	6:  {LineItem},
-	7:  {Supplier, LineItem, Orders, Customer, Nation},
-	8:  {Part, Supplier, LineItem, Orders, Customer, Nation, Region},
-	9:  {Part, Supplier, LineItem, PartSupp, Orders, Nation},
-	10: {Customer, Orders, LineItem, Nation},
-	11: {PartSupp, Supplier, Nation},
-	12: {Orders, LineItem},
-	13: {Customer, Orders},
-	14: {LineItem, Part},
-	15: {Supplier, LineItem},
-	16: {Part, PartSupp, Supplier},
-	17: {LineItem, Part},
-	18: {Customer, Orders, LineItem},
-	19: {LineItem, Part},
-	20: {Supplier, Nation, PartSupp, Part, LineItem},
-	25: {LineItem},
-}
+// TextPoolSize bytes of text that comments are picked from,
+// texts differ from the default pool if it's changed
+var TextPoolSize = 300 * 1024 * 1024
 
-var tpchQueryMainTableMap = map[int]TpchTable{
-	-1: LineItem,
-	1:  LineItem,
-	2:  PartSupp,
-	3:  LineItem,
-	4:  LineItem,
-	5:  LineItem,
-	6:  LineItem,
-	7:  LineItem,
-	8:  LineItem,
-	9:  LineItem,
-	10: LineItem,
-	11: PartSupp,
-	12: LineItem,
-	13: Orders,
-	14: LineItem,
-	15: LineItem,
-	16: PartSupp,
-	17: LineItem,
-	18: LineItem,
-	19: LineItem,
-	20: LineItem,
-	25: LineItem,
-}
+// TextPoolCacheDir the text pool is saved to and loaded from this directory, no cache if empty
+var TextPoolCacheDir string
 
 var TpchAllTables = []TpchTable{
 	LineItem,
@@ -89,29 +44,98 @@ var TpchAllTables = []TpchTable{
 	Region,
 }
 
+// TableStream a table sent in realtime, it gets Ratio/sum(Ratio) of the total rate
+type TableStream struct {
+	Table TpchTable
+	Ratio float64
+}
+
+// StreamDeclaration tables sent in realtime and their ratios, overrides the declaration of queries if not empty.
+// Tables of the query not declared are sent in batch in advance
+var StreamDeclaration = make([]*TableStream, 0)
+
+// ParseTableStreams parses declarations like "customer:1,orders:10,lineitem:40"
+func ParseTableStreams(s string) ([]*TableStream, error) {
+	streams := make([]*TableStream, 0)
+	if strings.TrimSpace(s) == "" {
+		return streams, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		words := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		ratio := 1.0
+		if len(words) == 2 {
+			var err error
+			ratio, err = strconv.ParseFloat(words[1], 64)
+			if err != nil || ratio <= 0 {
+				return nil, util.Errorf("stream declaration error, invalid ratio of %s: %s", words[0], words[1])
+			}
+		}
+		table := TpchTable(words[0])
+		if !IsTpchTable(table) {
+			return nil, util.Errorf("stream declaration error, unknown table: %s", words[0])
+		}
+		for _, stream := range streams {
+			if stream.Table == table {
+				return nil, util.Errorf("stream declaration error, %s is declared twice", table)
+			}
+		}
+		streams = append(streams, &TableStream{table, ratio})
+	}
+	return streams, nil
+}
+
+func IsTpchTable(table TpchTable) bool {
+	for _, t := range TpchAllTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultStreams orders and lineitem are streamed together at 1:4 if both are in the query,
+// otherwise only the main table is streamed
+func defaultStreams(mainTable TpchTable, tables []TpchTable) []*TableStream {
+	containOrder := false
+	containLineItem := false
+	for _, table := range tables {
+		if table == Orders {
+			containOrder = true
+		} else if table == LineItem {
+			containLineItem = true
+		}
+	}
+	if containOrder && containLineItem {
+		return []*TableStream{{LineItem, 4}, {Orders, 1}}
+	}
+	return []*TableStream{{mainTable, 1}}
+}
+
 type TpchBenchConfig struct {
-	QueryName   string      // ex: q1
-	Rate        int         // rate to generate data rows in main table
-	ScaleFactor float64     // Base: 1.0 = 1,500,000 orders
-	MainTable   TpchTable   // rate control related
-	Tables      []TpchTable // tables involved in the query
-	SqlConfig   *SqlConfig  // files containing ddl & query statements
+	QueryName   string         // ex: q1
+	Rate        int            // rate to generate data rows of all streamed tables
+	ScaleFactor float64        // Base: 1.0 = 1,500,000 orders
+	MainTable   TpchTable      // rate control related
+	Tables      []TpchTable    // tables involved in the query
+	SqlConfig   *SqlConfig     // files containing ddl & query statements
+	Streams     []*TableStream // tables sent in realtime, others are sent in batch
 }
 
-func NewTpchConfig(queryId int, rate int, scale float64) *TpchBenchConfig {
-	mainTable := selfDefineMainTable
-	tables := selfDefineTables
-	_, ok := tpchQueryMainTableMap[queryId]
-	if ok {
-		mainTable = tpchQueryMainTableMap[queryId]
-		tables = tpchQueryTableMap[queryId]
+func NewTpchConfig(query *Query, rate int, scale float64) *TpchBenchConfig {
+	streams := StreamDeclaration
+	if len(streams) == 0 {
+		streams = query.Streams
+	}
+	if len(streams) == 0 {
+		streams = defaultStreams(query.MainTable, query.Tables)
 	}
 	return &TpchBenchConfig{
-		fmt.Sprintf("q%d", queryId),
+		query.Name,
 		rate,
 		scale,
-		mainTable,
-		tables,
-		NewTpchSqlConfig(queryId),
+		query.MainTable,
+		query.Tables,
+		NewTpchSqlConfig(query),
+		streams,
 	}
 }
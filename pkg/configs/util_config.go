@@ -1,3 +1,35 @@
 package configs
 
+import "time"
+
 var CheckMVInterval int
+
+// SampleStableChecks after production finishes, sampling stops once this many consecutive results are the same
+var SampleStableChecks = 3
+
+// ConvergeTimeout max time to sample after production finishes if the result never becomes stable
+var ConvergeTimeout = 30 * time.Minute
+
+// ReadyWait whether to wait for tables sent in batch to be ingested before the mv is created and streaming starts
+var ReadyWait = true
+
+// ReadyTimeout max time to wait for tables sent in batch to be ingested, no timeout if 0
+var ReadyTimeout = 5 * time.Minute
+
+// ReadyPollInterval interval of polling row counts of tables sent in batch
+var ReadyPollInterval = time.Second
+
+// ReportPath json report of metrics is written to this path after the run, no report if empty
+var ReportPath string
+
+// CleanupOnInterrupt drop the mv, source tables and topics like tpch-clean when the benchmark is interrupted
+var CleanupOnInterrupt bool
+
+// CleanupTimeout max time of the cleanup after the benchmark is interrupted
+var CleanupTimeout = time.Minute
+
+// AdminOperationTimeout max time for kafka to create or delete topics
+var AdminOperationTimeout = time.Minute
+
+// DoctorTimeout max time of every check of `bench doctor` that connects to kafka or the frontend
+var DoctorTimeout = 10 * time.Second
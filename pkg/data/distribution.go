@@ -2,22 +2,40 @@ package data
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
 	"github.com/singularity-data/tpch-bench/pkg/configs"
 	"github.com/singularity-data/tpch-bench/pkg/util"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type Distribution struct {
 	Name      string
 	Terms     []string
-	Weights   []int
+	Weights   []int // accumulated weights loaded from the file, not affected by drift
 	inner     []string
 	WeightSum int
+	drifted   atomic.Value // *driftedTerms, replaces inner once the distribution drifts
+}
+
+type driftedTerms struct {
+	weights   []int
+	inner     []string
+	weightSum int
 }
 
 func (d *Distribution) RandomValue(randomInt *RandomInt) (string, error) {
+	if drifted, ok := d.drifted.Load().(*driftedTerms); ok && drifted != nil {
+		idx, err := randomInt.NextInt(0, drifted.weightSum-1)
+		if err != nil {
+			return "", err
+		}
+		return drifted.inner[idx], nil
+	}
 	if d.inner != nil {
 		idx, err := randomInt.NextInt(0, d.WeightSum-1)
 		if err != nil {
@@ -32,72 +50,199 @@ func (d *Distribution) Size() int {
 	return len(d.Terms)
 }
 
+// currentWeights weights of every term, including drift
+func (d *Distribution) currentWeights() []int {
+	if drifted, ok := d.drifted.Load().(*driftedTerms); ok && drifted != nil {
+		return drifted.weights
+	}
+	weights := make([]int, len(d.Weights))
+	for i := range d.Weights {
+		weights[i] = d.Weights[i]
+		if i > 0 {
+			weights[i] -= d.Weights[i-1]
+		}
+	}
+	return weights
+}
+
+// driftWeights returns new weights of every term, listed terms get new weights and others keep current weights
+func (d *Distribution) driftWeights(weights map[string]int) ([]int, error) {
+	if d.inner == nil {
+		return nil, util.Errorf("%s is not a distribution, it could not drift", d.Name)
+	}
+	newWeights := d.currentWeights()
+	for term, weight := range weights {
+		found := false
+		for i, t := range d.Terms {
+			if t == term {
+				newWeights[i] = weight
+				found = true
+			}
+		}
+		if !found {
+			return nil, util.Errorf("term %s does not exist in %s", term, d.Name)
+		}
+	}
+	sum := 0
+	for _, w := range newWeights {
+		sum += w
+	}
+	if sum <= 0 {
+		return nil, util.Errorf("weights of %s sum to 0 after drift", d.Name)
+	}
+	return newWeights, nil
+}
+
+// ResetDrift restores weights loaded from the file
+func (d *Distribution) ResetDrift() {
+	d.drifted.Store((*driftedTerms)(nil))
+}
+
+// Drift replaces weights of listed terms, it's safe to drift while other goroutines draw values
+func (d *Distribution) Drift(weights map[string]int) error {
+	newWeights, err := d.driftWeights(weights)
+	if err != nil {
+		return err
+	}
+	drifted := &driftedTerms{
+		newWeights,
+		make([]string, 0),
+		0,
+	}
+	for i, term := range d.Terms {
+		for j := 0; j < newWeights[i]; j++ {
+			drifted.inner = append(drifted.inner, term)
+		}
+		drifted.weightSum += newWeights[i]
+	}
+	d.drifted.Store(drifted)
+	return nil
+}
+
 // DistributionManager
 // singleton
 type DistributionManager struct {
 	distributions map[string]*Distribution
+	checksum      string // sha256 of the distribution file
+}
+
+// RequiredDistributions distributions used by table generators and the text pool
+var RequiredDistributions = []string{
+	"p_cntr", "instruct", "msegmnt", "nations", "regions", "o_oprio", "rflag", "smode", "p_types", "colors",
+	"grammar", "np", "vp", "nouns", "verbs", "adjectives", "adverbs", "articles", "prepositions", "auxillaries",
+	"terminators",
 }
 
 var onceDistributionManager sync.Once
 var distributionManagerSingleton *DistributionManager
+var distributionManagerErr error
 
 func GetDistributionManager() *DistributionManager {
 	onceDistributionManager.Do(func() {
-		var err error
-		distributionManagerSingleton, err = LoadDistributions(configs.TpchDistributionPath)
-		if err != nil {
-			util.LogErr(err.Error())
+		distributionManagerSingleton, distributionManagerErr = LoadDistributions(configs.TpchDistributionPath)
+		if distributionManagerErr != nil {
+			util.LogErr(distributionManagerErr.Error())
 		}
 	})
 	return distributionManagerSingleton
 }
 
+// CheckDistributions loads distributions if not loaded yet, and returns the error of loading
+func CheckDistributions() error {
+	GetDistributionManager()
+	return distributionManagerErr
+}
+
+// distFileScanner counts line numbers for error messages
+type distFileScanner struct {
+	*bufio.Scanner
+	path    string
+	lineNum int
+}
+
+func (s *distFileScanner) Scan() bool {
+	ok := s.Scanner.Scan()
+	if ok {
+		s.lineNum++
+	}
+	return ok
+}
+
+func (s *distFileScanner) Errorf(str string, args ...interface{}) error {
+	return util.Errorf("%s:%d: %s", s.path, s.lineNum, fmt.Sprintf(str, args...))
+}
+
 func LoadDistributions(path string) (*DistributionManager, error) {
+	content, err := configs.ReadAsset(path)
+	if err != nil {
+		return nil, util.Errorf("read distribution file error: %s", err.Error())
+	}
 	d := new(DistributionManager)
 	d.distributions = make(map[string]*Distribution, 22)
-	scanner := util.ReadFile(path)
+	d.checksum = fmt.Sprintf("%x", sha256.Sum256(content))
+	scanner := &distFileScanner{bufio.NewScanner(bytes.NewReader(content)), path, 0}
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if len(line) == 0 || line[0] == '#' {
 			continue
 		}
-		words := strings.SplitN(line, " ", 2)
-		if words[0] == "begin" || words[0] == "BEGIN" {
-			scanner.Scan() // ignore the "count" line
-			dis, err := parseDistribution(words[1], scanner)
-			if err != nil {
-				return nil, err
-			}
-			d.distributions[dis.Name] = dis
-		} else {
-			return nil, util.Errorf("distribution file parse error, expected: BEGIN, found: %s", words[0])
+		words := strings.Fields(line)
+		if (words[0] != "begin" && words[0] != "BEGIN") || len(words) != 2 {
+			return nil, scanner.Errorf("distribution file parse error, expected: BEGIN <name>, found: %s", line)
+		}
+		if _, exist := d.distributions[words[1]]; exist {
+			return nil, scanner.Errorf("distribution %s is defined twice", words[1])
+		}
+		dis, err := parseDistribution(words[1], scanner)
+		if err != nil {
+			return nil, err
+		}
+		d.distributions[dis.Name] = dis
+	}
+	for _, name := range RequiredDistributions {
+		if _, exist := d.distributions[name]; !exist {
+			return nil, util.Errorf("%s: required distribution %s does not exist", path, name)
 		}
 	}
 	return d, nil
 }
 
-func parseDistribution(name string, scanner *bufio.Scanner) (*Distribution, error) {
+func parseDistribution(name string, scanner *distFileScanner) (*Distribution, error) {
+	if !scanner.Scan() {
+		return nil, scanner.Errorf("distribution %s is not terminated", name)
+	}
+	countLine := strings.Split(strings.TrimSpace(scanner.Text()), "|")
+	if len(countLine) != 2 || strings.ToLower(countLine[0]) != "count" {
+		return nil, scanner.Errorf("distribution file parse error, expected: COUNT|<n>, found: %s", scanner.Text())
+	}
+	count, err := strconv.Atoi(countLine[1])
+	if err != nil {
+		return nil, scanner.Errorf("distribution file parse error, %s", err.Error())
+	}
+
 	terms := make([]string, 0)
 	weights := make([]int, 0)
 	weightSum := 0
 	isValid := true
+	terminated := false
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		words := strings.SplitN(line, " ", 2)
-		if words[0] == "end" || words[0] == "END" {
-			if words[1] != name {
-				return nil, util.Errorf("distribution file parse error, expected: %s, found: %s", name, words[1])
+		words := strings.Fields(line)
+		if len(words) > 0 && (words[0] == "end" || words[0] == "END") {
+			if len(words) != 2 || words[1] != name {
+				return nil, scanner.Errorf("distribution file parse error, expected: END %s, found: %s", name, line)
 			}
+			terminated = true
 			break
 		}
 		words = strings.Split(line, "|")
 		if len(words) != 2 {
-			return nil, util.Errorf("distribution file parse error, found: %s", line)
+			return nil, scanner.Errorf("distribution file parse error, expected: <term>|<weight>, found: %s", line)
 		}
 		terms = append(terms, words[0])
 		weight, err := strconv.Atoi(words[1])
 		if err != nil {
-			return nil, util.Errorf("distribution file parse error, %s", err.Error())
+			return nil, scanner.Errorf("distribution file parse error, %s", err.Error())
 		}
 		if weight <= 0 {
 			isValid = false
@@ -105,6 +250,12 @@ func parseDistribution(name string, scanner *bufio.Scanner) (*Distribution, erro
 		weightSum += weight
 		weights = append(weights, weight)
 	}
+	if !terminated {
+		return nil, scanner.Errorf("distribution %s is not terminated", name)
+	}
+	if count != len(terms) {
+		return nil, scanner.Errorf("distribution %s expects %d terms, found %d", name, count, len(terms))
+	}
 	if !isValid {
 		for i := 1; i < len(weights); i++ {
 			weights[i] += weights[i-1]
@@ -115,6 +266,7 @@ func parseDistribution(name string, scanner *bufio.Scanner) (*Distribution, erro
 			weights,
 			nil,
 			-1,
+			atomic.Value{},
 		}, nil
 	}
 
@@ -137,9 +289,14 @@ func parseDistribution(name string, scanner *bufio.Scanner) (*Distribution, erro
 		weights,
 		inner,
 		weightSum,
+		atomic.Value{},
 	}, nil
 }
 
+func (d *DistributionManager) Checksum() string {
+	return d.checksum
+}
+
 func (d *DistributionManager) GetDistribution(name string) (*Distribution, error) {
 	re, exist := d.distributions[name]
 	if exist == false {
@@ -147,3 +304,17 @@ func (d *DistributionManager) GetDistribution(name string) (*Distribution, error
 	}
 	return re, nil
 }
+
+// ValidateDrift checks that every drift point could be applied, without applying it
+func (d *DistributionManager) ValidateDrift(points []*configs.DriftPoint) error {
+	for _, point := range points {
+		dist, err := d.GetDistribution(point.Distribution)
+		if err != nil {
+			return util.Errorf("drift at %v: %s", point.At, err.Error())
+		}
+		if _, err := dist.driftWeights(point.Weights); err != nil {
+			return util.Errorf("drift at %v: %s", point.At, err.Error())
+		}
+	}
+	return nil
+}
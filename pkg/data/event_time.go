@@ -0,0 +1,36 @@
+package data
+
+import (
+	"sync"
+	"time"
+)
+
+// EventTimeLayout format of event-time columns, accepted as TIMESTAMP by RisingWave
+const EventTimeLayout string = "2006-01-02 15:04:05.000"
+
+// EventClock maps wall clock to event time.
+// Event time starts at the wall clock of the first call to Now,
+// and then advances `speedup` times faster than wall clock.
+type EventClock struct {
+	once    sync.Once
+	start   time.Time
+	speedup float64
+}
+
+func NewEventClock(speedup float64) *EventClock {
+	return &EventClock{
+		speedup: speedup,
+	}
+}
+
+func (e *EventClock) Now() time.Time {
+	e.once.Do(func() {
+		e.start = time.Now()
+	})
+	elapsed := time.Now().Sub(e.start)
+	return e.start.Add(time.Duration(float64(elapsed) * e.speedup))
+}
+
+func FormatEventTime(t time.Time) string {
+	return t.Format(EventTimeLayout)
+}
@@ -2,15 +2,30 @@ package data
 
 import (
 	"fmt"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
 	"github.com/singularity-data/tpch-bench/pkg/util"
 	"math"
 )
 
 const (
-	Multiplier int64 = 16807
-	Mod        int64 = math.MaxInt32
+	Multiplier       int64 = 16807
+	Mod              int64 = math.MaxInt32
+	SeedOffsetStride int64 = 1_000_003
 )
 
+// PerturbSeed moves a seed by configs.SeedOffset, the result stays within [1, Mod-1]
+func PerturbSeed(seed int64) int64 {
+	if configs.SeedOffset == 0 {
+		return seed
+	}
+	offset := (configs.SeedOffset % (Mod - 1)) * SeedOffsetStride
+	re := (seed + offset) % (Mod - 1)
+	if re < 0 {
+		re += Mod - 1
+	}
+	return re + 1
+}
+
 type IntBaseGenerator struct {
 	usageTimesPerRow int
 	usage            int
@@ -21,7 +36,7 @@ func NewIntBaseGenerator(seed int64, usageTimesPerRow int) *IntBaseGenerator {
 	return &IntBaseGenerator{
 		usageTimesPerRow,
 		0,
-		seed,
+		PerturbSeed(seed),
 	}
 }
 
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"github.com/singularity-data/tpch-bench/pkg/configs"
 	"github.com/singularity-data/tpch-bench/pkg/util"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type LineItem struct {
@@ -27,6 +29,7 @@ type LineItem struct {
 	LShipinstruct  string      `json:"l_shipinstruct"`
 	LShipmode      string      `json:"l_shipmode"`
 	LComment       string      `json:"l_comment"`
+	LEventtime     string      `json:"l_eventtime,omitempty"`
 }
 
 const (
@@ -53,6 +56,9 @@ type LineItemGenerator struct {
 	distManager *DistributionManager
 	textPool    *TextPool
 	iter        *LineItemGeneratorIter
+	clock       *EventClock
+	eventTime   time.Time
+	lastRowId   int64
 }
 
 func NewLineItemGenerator(scaleFactor float64, part int, partCnt int) *LineItemGenerator {
@@ -63,6 +69,9 @@ func NewLineItemGenerator(scaleFactor float64, part int, partCnt int) *LineItemG
 		GetDistributionManager(),
 		GetTextPool(),
 		nil,
+		nil,
+		time.Time{},
+		0,
 	}
 	l.iter = NewLineItemGeneratorIter(l.distManager, l.textPool,
 		CalcuStart(OrderScaleBase, scaleFactor, part, partCnt),
@@ -73,18 +82,43 @@ func NewLineItemGenerator(scaleFactor float64, part int, partCnt int) *LineItemG
 
 func (l *LineItemGenerator) Next() []byte {
 	item := l.iter.Next()
+	if item != nil {
+		l.lastRowId = item.RowId
+	}
+	if item != nil && l.clock != nil {
+		l.eventTime = l.clock.Now()
+		item.LEventtime = FormatEventTime(l.eventTime)
+	}
 	bytes, _ := json.Marshal(item)
 	return bytes
 }
 
 func (l *LineItemGenerator) Capacity() int64 {
+	if l.iter.unbounded {
+		return math.MaxInt64
+	}
 	return l.iter.rowCnt * 4
 }
 
+// SetUnbounded keeps generating lineitems after the scale factor is reached, see OrderGeneratorIter.nextRound
+func (l *LineItemGenerator) SetUnbounded() {
+	l.iter.unbounded = true
+}
+
+func (l *LineItemGenerator) LastRowId() int64 {
+	return l.lastRowId
+}
+
+func (l *LineItemGenerator) EventTime() time.Time {
+	return l.eventTime
+}
+
 type LineItemGeneratorIter struct {
 	idx             int64
 	start           int64
 	rowCnt          int64
+	total           int64 // orders of all parts
+	unbounded       bool
 	scaleFactor     float64
 	orderDate       int
 	lineCnt         int
@@ -96,6 +130,7 @@ type LineItemGeneratorIter struct {
 	tax             *BoundedRandomInt
 	linePartKey     *BoundedRandomLong
 	supplierNumber  *BoundedRandomInt
+	suppKey         *BoundedRandomLong // nil if l_suppkey is not skewed
 	shipDate        *BoundedRandomInt
 	commitDate      *BoundedRandomInt
 	receiptDate     *BoundedRandomInt
@@ -103,6 +138,8 @@ type LineItemGeneratorIter struct {
 	shipInstruction *RandomString
 	shipMode        *RandomString
 	comment         *RandomText
+	partKeyCounter  *KeyCounter
+	suppKeyCounter  *KeyCounter
 }
 
 func NewLineItemGeneratorIter(distManager *DistributionManager, pool *TextPool, start int64, rowCnt int64, factor float64) *LineItemGeneratorIter {
@@ -110,6 +147,8 @@ func NewLineItemGeneratorIter(distManager *DistributionManager, pool *TextPool,
 		0,
 		start,
 		rowCnt,
+		int64(float64(OrderScaleBase) * factor),
+		false,
 		factor,
 		-1,
 		-1,
@@ -121,6 +160,7 @@ func NewLineItemGeneratorIter(distManager *DistributionManager, pool *TextPool,
 		LineItemRandom("tax"),
 		LinePartKey(factor),
 		NewBoundedRandomInt(2095021727, LineCntMax, 0, 3),
+		LineSuppKey(factor),
 		LineItemRandom("shipDate"),
 		NewBoundedRandomInt(904914315, LineCntMax, LineItemCommitDateMin, LineItemCommitDateMax),
 		NewBoundedRandomInt(373135028, LineCntMax, LineItemReceiptDateMin, LineItemReceiptDateMax),
@@ -128,6 +168,8 @@ func NewLineItemGeneratorIter(distManager *DistributionManager, pool *TextPool,
 		nil,
 		nil,
 		NewRandomText(1095462486, float64(LineItemCommentAverLen), LineCntMax, pool),
+		NewKeyCounter(configs.SkewLPartKey),
+		NewKeyCounter(configs.SkewLSuppKey),
 	}
 	flags, _ := distManager.GetDistribution("rflag")
 	l.returnedFlag = NewRandomString(717419739, flags, LineCntMax)
@@ -143,6 +185,9 @@ func NewLineItemGeneratorIter(distManager *DistributionManager, pool *TextPool,
 }
 
 func (l *LineItemGeneratorIter) Next() *LineItem {
+	if l.idx >= l.rowCnt && l.unbounded {
+		l.nextRound()
+	}
 	if l.idx >= l.rowCnt {
 		return nil
 	}
@@ -157,6 +202,11 @@ func (l *LineItemGeneratorIter) Next() *LineItem {
 	partKey, _ := l.linePartKey.NextValue()
 	supplierNumber, _ := l.supplierNumber.NextValue()
 	supplierKey := SelectPartSupp(partKey, int64(supplierNumber), l.scaleFactor)
+	if l.suppKey != nil {
+		supplierKey, _ = l.suppKey.NextValue()
+	}
+	l.partKeyCounter.Add(partKey)
+	l.suppKeyCounter.Add(supplierKey)
 
 	partPrice := CalcuPartPrice(partKey)
 	extendPrice := partPrice * int64(quantity)
@@ -204,6 +254,7 @@ func (l *LineItemGeneratorIter) Next() *LineItem {
 		shipInstruction,
 		shipMode,
 		comment,
+		"",
 	}
 	l.lineNumber++
 
@@ -215,6 +266,9 @@ func (l *LineItemGeneratorIter) Next() *LineItem {
 		l.tax.FinishRow()
 		l.linePartKey.FinishRow()
 		l.supplierNumber.FinishRow()
+		if l.suppKey != nil {
+			l.suppKey.FinishRow()
+		}
 		l.shipDate.FinishRow()
 		l.commitDate.FinishRow()
 		l.receiptDate.FinishRow()
@@ -232,6 +286,37 @@ func (l *LineItemGeneratorIter) Next() *LineItem {
 	return lineItem
 }
 
+// nextRound same as OrderGeneratorIter.nextRound
+func (l *LineItemGeneratorIter) nextRound() {
+	skip := l.total - l.rowCnt
+	l.start += l.total
+	l.idx = 0
+	if skip == 0 {
+		return
+	}
+	// order date and line count of the first order after this part are drawn already
+	l.orderDateRandom.AdvanceRows(skip - 1)
+	l.lineCntRandom.AdvanceRows(skip - 1)
+	l.qty.AdvanceRows(skip)
+	l.discount.AdvanceRows(skip)
+	l.tax.AdvanceRows(skip)
+	l.linePartKey.AdvanceRows(skip)
+	l.supplierNumber.AdvanceRows(skip)
+	if l.suppKey != nil {
+		l.suppKey.AdvanceRows(skip)
+	}
+	l.shipDate.AdvanceRows(skip)
+	l.commitDate.AdvanceRows(skip)
+	l.receiptDate.AdvanceRows(skip)
+	l.returnedFlag.AdvanceRows(skip)
+	l.shipInstruction.AdvanceRows(skip)
+	l.shipMode.AdvanceRows(skip)
+	l.comment.AdvanceRows(skip)
+	l.orderDate, _ = l.orderDateRandom.NextValue()
+	l.lineCnt, _ = l.lineCntRandom.NextValue()
+	l.lineCnt--
+}
+
 func LineItemRandom(random string) *BoundedRandomInt {
 	switch random {
 	case "qty":
@@ -248,7 +333,16 @@ func LineItemRandom(random string) *BoundedRandomInt {
 }
 
 func LinePartKey(scale float64) *BoundedRandomLong {
-	return NewBoundedRandomLong(scale >= 30000, 1808217256, LineCntMax, int64(LineItemPartKeyMin), int64(float64(PartScaleBase)*scale))
+	return NewSkewedRandomLong(configs.SkewLPartKey, scale >= 30000, 1808217256, LineCntMax, int64(LineItemPartKeyMin), int64(float64(PartScaleBase)*scale))
+}
+
+// LineSuppKey only used if l_suppkey is skewed, the skewed supplier is not necessarily
+// one of the suppliers of the part, so (l_partkey, l_suppkey) may have no match in partsupp
+func LineSuppKey(scale float64) *BoundedRandomLong {
+	if _, ok := configs.KeySkews[configs.SkewLSuppKey]; !ok {
+		return nil
+	}
+	return NewSkewedRandomLong(configs.SkewLSuppKey, scale >= 30000, 1940305277, LineCntMax, 1, int64(float64(SupplierScaleBase)*scale))
 }
 
 func LineItemsFromTblFile() ([][]byte, error) {
@@ -292,6 +386,7 @@ func LineItemsFromTblFile() ([][]byte, error) {
 			tokens[13],
 			tokens[14],
 			tokens[15],
+			"",
 		}
 
 		itemBytes, err := json.Marshal(lineItem)
@@ -363,6 +458,7 @@ func LineItemsFromSqlFile() ([][]byte, error) {
 			tokens2[5],
 			tokens2[6],
 			tokens2[7],
+			"",
 		}
 
 		itemBytes, err := json.Marshal(lineItem)
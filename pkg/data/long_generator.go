@@ -1,6 +1,7 @@
 package data
 
 import (
+	"github.com/singularity-data/tpch-bench/pkg/configs"
 	"github.com/singularity-data/tpch-bench/pkg/util"
 	"math"
 )
@@ -20,7 +21,7 @@ func NewLongBaseGenerator(seed int64, usageTimesPerRow int) *LongBaseGenerator {
 	return &LongBaseGenerator{
 		usageTimesPerRow,
 		0,
-		seed,
+		PerturbSeed(seed),
 	}
 }
 
@@ -70,6 +71,9 @@ type BoundedRandomLong struct {
 	longGenerator *LongBaseGenerator
 	low           int64
 	high          int64
+	skew          *configs.SkewSpec // nil if uniform, see NewSkewedRandomLong
+	skewLow       int64
+	skewHigh      int64
 }
 
 func NewBoundedRandomLong(useLong bool, seed int64, usageTimesPerRow int, low int64, high int64) *BoundedRandomLong {
@@ -87,11 +91,20 @@ func NewBoundedRandomLong(useLong bool, seed int64, usageTimesPerRow int, low in
 }
 
 func (b *BoundedRandomLong) NextValue() (int64, error) {
+	var re int64
+	var err error
 	if b.longGenerator != nil {
-		return b.longGenerator.NextLong(b.low, b.high)
+		re, err = b.longGenerator.NextLong(b.low, b.high)
+	} else {
+		var v int
+		v, err = b.intGenerator.NextInt(int(b.low), int(b.high))
+		re = int64(v)
+	}
+	if err != nil || b.skew == nil {
+		return re, err
 	}
-	re, err := b.intGenerator.NextInt(int(b.low), int(b.high))
-	return int64(re), err
+	u := float64(re) / float64(SkewResolution)
+	return b.skewLow + skewRank(b.skew, u, b.skewHigh-b.skewLow+1) - 1, nil
 }
 
 func (b *BoundedRandomLong) FinishRow() {
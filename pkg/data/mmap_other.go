@@ -0,0 +1,19 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package data
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the whole file on platforms without mmap
+func mapFile(f *os.File, size int) ([]byte, error) {
+	inner := make([]byte, size)
+	_, err := io.ReadFull(f, inner)
+	if err != nil {
+		return nil, err
+	}
+	return inner, nil
+}
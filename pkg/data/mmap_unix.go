@@ -0,0 +1,14 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package data
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the file read-only, pages are shared between runs through the page cache
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
@@ -3,7 +3,9 @@ package data
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
 	"math"
+	"time"
 )
 
 type Order struct {
@@ -17,6 +19,7 @@ type Order struct {
 	OClerk         string      `json:"o_clerk"`
 	OShippriority  int64       `json:"o_shippriority"`
 	OComment       string      `json:"o_comment"`
+	OEventtime     string      `json:"o_eventtime,omitempty"`
 }
 
 const (
@@ -39,6 +42,9 @@ type OrderGenerator struct {
 	distManager *DistributionManager
 	textPool    *TextPool
 	iter        *OrderGeneratorIter
+	clock       *EventClock
+	eventTime   time.Time
+	lastRowId   int64
 }
 
 func NewOrderGenerator(scaleFactor float64, part int, partCnt int) *OrderGenerator {
@@ -49,6 +55,9 @@ func NewOrderGenerator(scaleFactor float64, part int, partCnt int) *OrderGenerat
 		GetDistributionManager(),
 		GetTextPool(),
 		nil,
+		nil,
+		time.Time{},
+		0,
 	}
 	o.iter = NewOrderGeneratorIter(o.distManager, o.textPool,
 		CalcuStart(OrderScaleBase, scaleFactor, part, partCnt),
@@ -59,18 +68,43 @@ func NewOrderGenerator(scaleFactor float64, part int, partCnt int) *OrderGenerat
 
 func (o *OrderGenerator) Next() []byte {
 	item := o.iter.Next()
+	if item != nil {
+		o.lastRowId = item.RowId
+	}
+	if item != nil && o.clock != nil {
+		o.eventTime = o.clock.Now()
+		item.OEventtime = FormatEventTime(o.eventTime)
+	}
 	bytes, _ := json.Marshal(item)
 	return bytes
 }
 
 func (o *OrderGenerator) Capacity() int64 {
+	if o.iter.unbounded {
+		return math.MaxInt64
+	}
 	return o.iter.rowCnt
 }
 
+// SetUnbounded keeps generating orders after the scale factor is reached, see OrderGeneratorIter.nextRound
+func (o *OrderGenerator) SetUnbounded() {
+	o.iter.unbounded = true
+}
+
+func (o *OrderGenerator) LastRowId() int64 {
+	return o.lastRowId
+}
+
+func (o *OrderGenerator) EventTime() time.Time {
+	return o.eventTime
+}
+
 type OrderGeneratorIter struct {
 	idx            int64
 	start          int64
 	rowCnt         int64
+	total          int64 // orders of all parts
+	unbounded      bool
 	maxCustomerKey int64
 	orderDate      *BoundedRandomInt
 	lineCnt        *BoundedRandomInt
@@ -83,6 +117,7 @@ type OrderGeneratorIter struct {
 	lineTax        *BoundedRandomInt
 	linePartKey    *BoundedRandomLong
 	lineShipDate   *BoundedRandomInt
+	custKeyCounter *KeyCounter
 }
 
 func NewOrderGeneratorIter(distManager *DistributionManager, pool *TextPool, start int64, rowCnt int64, factor float64) *OrderGeneratorIter {
@@ -90,6 +125,8 @@ func NewOrderGeneratorIter(distManager *DistributionManager, pool *TextPool, sta
 		0,
 		start,
 		rowCnt,
+		int64(float64(OrderScaleBase) * factor),
+		false,
 		int64(float64(CustomerScaleBase) * factor),
 		NewBoundedRandomInt(1066728069, 1, OrderDateMin, OrderDateMax),
 		NewBoundedRandomInt(1434868289, 1, LineCntMin, LineCntMax),
@@ -102,8 +139,9 @@ func NewOrderGeneratorIter(distManager *DistributionManager, pool *TextPool, sta
 		LineItemRandom("tax"),
 		LinePartKey(factor),
 		LineItemRandom("shipDate"),
+		NewKeyCounter(configs.SkewOCustKey),
 	}
-	o.customerKey = NewBoundedRandomLong(factor >= 30000, 851767375, 1, 1, o.maxCustomerKey)
+	o.customerKey = NewSkewedRandomLong(configs.SkewOCustKey, factor >= 30000, 851767375, 1, 1, o.maxCustomerKey)
 	priorities, _ := distManager.GetDistribution("o_oprio")
 	o.orderPriority = NewRandomString(591449447, priorities, 1)
 
@@ -122,6 +160,9 @@ func NewOrderGeneratorIter(distManager *DistributionManager, pool *TextPool, sta
 }
 
 func (o *OrderGeneratorIter) Next() *Order {
+	if o.idx >= o.rowCnt && o.unbounded {
+		o.nextRound()
+	}
 	if o.idx >= o.rowCnt {
 		return nil
 	}
@@ -137,6 +178,7 @@ func (o *OrderGeneratorIter) Next() *Order {
 		customerKey = int64(math.Min(float64(customerKey), float64(o.maxCustomerKey)))
 		delta *= -1
 	}
+	o.custKeyCounter.Add(customerKey)
 
 	totalPrice := int64(0)
 	shippedCnt := 0
@@ -194,6 +236,25 @@ func (o *OrderGeneratorIter) Next() *Order {
 	return order
 }
 
+// nextRound moves to the same part of the next round, rows of round r are in [r*total, (r+1)*total),
+// so order keys keep growing and parts never overlap, while foreign keys stay within the scale factor
+func (o *OrderGeneratorIter) nextRound() {
+	skip := o.total - o.rowCnt
+	o.orderDate.AdvanceRows(skip)
+	o.lineCnt.AdvanceRows(skip)
+	o.customerKey.AdvanceRows(skip)
+	o.orderPriority.AdvanceRows(skip)
+	o.clerk.AdvanceRows(skip)
+	o.comment.AdvanceRows(skip)
+	o.lineQty.AdvanceRows(skip)
+	o.lineDiscount.AdvanceRows(skip)
+	o.lineTax.AdvanceRows(skip)
+	o.linePartKey.AdvanceRows(skip)
+	o.lineShipDate.AdvanceRows(skip)
+	o.start += o.total
+	o.idx = 0
+}
+
 func MakeOrderKey(orderIdx int64) int64 {
 	orderKey := orderIdx
 	orderKey >>= OrderKeySparseKeep
@@ -1,6 +1,9 @@
 package data
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+)
 
 type PartSupp struct {
 	RowId        int64       `json:"-"`
@@ -60,9 +63,12 @@ type PartSuppGeneratorIter struct {
 	rowCnt             int64
 	scaleFactor        float64
 	partSupplierNumber int
+	partKey            int64
 	availQty           *BoundedRandomInt
 	supplyCost         *BoundedRandomInt
 	comment            *RandomText
+	skewedPartKey      *BoundedRandomLong // nil if ps_partkey is not skewed
+	partKeyCounter     *KeyCounter
 }
 
 func NewPartSuppGeneratorIter(pool *TextPool, start int64, rowCnt int64, factor float64) *PartSuppGeneratorIter {
@@ -72,9 +78,16 @@ func NewPartSuppGeneratorIter(pool *TextPool, start int64, rowCnt int64, factor
 		rowCnt,
 		factor,
 		0,
+		0,
 		NewBoundedRandomInt(1671059989, PSSuppliersPerPart, PSAvailableQtyMin, PSAvailableQtyMax),
 		NewBoundedRandomInt(1051288424, PSSuppliersPerPart, PSSupplyCostMin, PSSupplyCostMax),
 		NewRandomText(1961692154, float64(PSCommentAverLen), PSSuppliersPerPart, pool),
+		nil,
+		NewKeyCounter(configs.SkewPSPartKey),
+	}
+	if _, ok := configs.KeySkews[configs.SkewPSPartKey]; ok {
+		ps.skewedPartKey = NewSkewedRandomLong(configs.SkewPSPartKey, factor >= 30000, 1583542386, 1, 1, int64(float64(PartScaleBase)*factor))
+		ps.skewedPartKey.AdvanceRows(start)
 	}
 
 	ps.availQty.AdvanceRows(start)
@@ -89,7 +102,14 @@ func (ps *PartSuppGeneratorIter) Next() *PartSupp {
 	}
 
 	partSupp := new(PartSupp)
-	partKey := ps.start + ps.idx + 1
+	if ps.partSupplierNumber == 0 {
+		ps.partKey = ps.start + ps.idx + 1
+		if ps.skewedPartKey != nil {
+			ps.partKey, _ = ps.skewedPartKey.NextValue()
+		}
+	}
+	partKey := ps.partKey
+	ps.partKeyCounter.Add(partKey)
 	partSupp.RowId = partKey
 	partSupp.PSPartkey = partKey
 	partSupp.PSSuppkey = SelectPartSupp(partKey, int64(ps.partSupplierNumber), ps.scaleFactor)
@@ -104,6 +124,9 @@ func (ps *PartSuppGeneratorIter) Next() *PartSupp {
 		ps.availQty.FinishRow()
 		ps.supplyCost.FinishRow()
 		ps.comment.FinishRow()
+		if ps.skewedPartKey != nil {
+			ps.skewedPartKey.FinishRow()
+		}
 		ps.idx++
 		ps.partSupplierNumber = 0
 	}
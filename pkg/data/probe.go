@@ -0,0 +1,38 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ProbeShipDate ship date of probe rows, later than any date filter of tpch queries
+const ProbeShipDate string = "2099-12-31"
+
+// NewProbeLineItem a lineitem row tagged as probe `probeId` (> 0).
+// Probe rows use the reserved negative orderkeys and have no matching part, supplier or order,
+// so they never affect results of tpch queries
+func NewProbeLineItem(probeId int64, sent time.Time) []byte {
+	item := &LineItem{
+		probeId,
+		-probeId,
+		-1,
+		-1,
+		1,
+		json.Number("0"),
+		json.Number("0.00"),
+		json.Number("0.00"),
+		json.Number("0.00"),
+		"N",
+		"O",
+		ProbeShipDate,
+		ProbeShipDate,
+		ProbeShipDate,
+		"NONE",
+		"PROBE",
+		fmt.Sprintf("probe sent at %s", sent.Format(EventTimeLayout)),
+		"",
+	}
+	bytes, _ := json.Marshal(item)
+	return bytes
+}
@@ -0,0 +1,165 @@
+package data
+
+import (
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"math"
+	"sort"
+	"sync"
+)
+
+// SkewResolution number of buckets of the uniform draw that a skewed key is mapped from
+const SkewResolution int64 = 1 << 30
+
+// skewRank maps a uniform draw u in [0, 1) to a rank in [1, n], rank 1 is the hottest key.
+// Exactly one draw is needed per key, so skewed generators consume seeds
+// at the same pace as uniform ones and AdvanceRows stays valid
+func skewRank(spec *configs.SkewSpec, u float64, n int64) int64 {
+	var rank int64
+	switch spec.Kind {
+	case configs.Zipf:
+		if spec.Exponent == 1 {
+			rank = int64(math.Pow(float64(n), u))
+		} else {
+			e := 1 - spec.Exponent
+			rank = int64(math.Pow((math.Pow(float64(n), e)-1)*u+1, 1/e))
+		}
+	case configs.Hotspot:
+		hot := int64(math.Max(spec.HotKeys*float64(n), 1))
+		if u < spec.HotRows {
+			rank = 1 + int64(u/spec.HotRows*float64(hot))
+		} else {
+			rank = hot + 1 + int64((u-spec.HotRows)/(1-spec.HotRows)*float64(n-hot))
+		}
+	default:
+		rank = 1 + int64(u*float64(n))
+	}
+	if rank < 1 {
+		rank = 1
+	} else if rank > n {
+		rank = n
+	}
+	return rank
+}
+
+// NewSkewedRandomLong generates keys in [low, high] following the skew of `column`,
+// key low+r-1 is the r-th hottest one. Keys are uniform if the column is not skewed
+func NewSkewedRandomLong(column string, useLong bool, seed int64, usageTimesPerRow int, low int64, high int64) *BoundedRandomLong {
+	spec, ok := configs.KeySkews[column]
+	if !ok {
+		return NewBoundedRandomLong(useLong, seed, usageTimesPerRow, low, high)
+	}
+	b := NewBoundedRandomLong(useLong, seed, usageTimesPerRow, 0, SkewResolution-1)
+	b.skew = spec
+	b.skewLow = low
+	b.skewHigh = high
+	return b
+}
+
+type KeyFreq struct {
+	Key   int64
+	Count int64
+}
+
+// KeyCounter counts keys generated by one generator, it's not thread safe.
+// All counters of the same column are merged when reporting
+type KeyCounter struct {
+	column string
+	total  int64
+	counts map[int64]int64
+}
+
+var keyCountersMutex sync.Mutex
+var keyCounters = make([]*KeyCounter, 0)
+
+// NewKeyCounter returns nil if the column is not skewed
+func NewKeyCounter(column string) *KeyCounter {
+	if _, ok := configs.KeySkews[column]; !ok {
+		return nil
+	}
+	c := &KeyCounter{
+		column,
+		0,
+		make(map[int64]int64),
+	}
+	keyCountersMutex.Lock()
+	keyCounters = append(keyCounters, c)
+	keyCountersMutex.Unlock()
+	return c
+}
+
+// ResetKeyCounters forgets counters of generators built before, so that keys of a previous run are never reported again
+func ResetKeyCounters() {
+	keyCountersMutex.Lock()
+	defer keyCountersMutex.Unlock()
+	keyCounters = make([]*KeyCounter, 0)
+}
+
+// ClearKeyCounts zeros all counters, ex: keys sent in batch are not reported with keys sent in realtime.
+// It must not be called while generators are running
+func ClearKeyCounts() {
+	keyCountersMutex.Lock()
+	defer keyCountersMutex.Unlock()
+	for _, c := range keyCounters {
+		c.total = 0
+		c.counts = make(map[int64]int64)
+	}
+}
+
+func (c *KeyCounter) Add(key int64) {
+	if c == nil {
+		return
+	}
+	c.counts[key]++
+	c.total++
+}
+
+// TopKeys returns the k most frequent keys of a column and the number of counted keys
+func TopKeys(column string, k int) ([]KeyFreq, int64) {
+	keyCountersMutex.Lock()
+	defer keyCountersMutex.Unlock()
+	merged := make(map[int64]int64)
+	total := int64(0)
+	for _, c := range keyCounters {
+		if c.column != column {
+			continue
+		}
+		for key, cnt := range c.counts {
+			merged[key] += cnt
+		}
+		total += c.total
+	}
+	freqs := make([]KeyFreq, 0, len(merged))
+	for key, cnt := range merged {
+		freqs = append(freqs, KeyFreq{key, cnt})
+	}
+	sort.Slice(freqs, func(i, j int) bool {
+		if freqs[i].Count != freqs[j].Count {
+			return freqs[i].Count > freqs[j].Count
+		}
+		return freqs[i].Key < freqs[j].Key
+	})
+	if len(freqs) > k {
+		freqs = freqs[:k]
+	}
+	return freqs, total
+}
+
+// ReportKeySkew logs the top-k key frequencies of all skewed columns
+func ReportKeySkew(k int) {
+	columns := make([]string, 0, len(configs.KeySkews))
+	for column := range configs.KeySkews {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		freqs, total := TopKeys(column, k)
+		if total == 0 {
+			continue
+		}
+		util.LogInfo("skew of %s: %d keys counted, top %d:", column, total, len(freqs))
+		for i, f := range freqs {
+			util.LogInfo("  #%d key[%d] count[%d] freq[%.4f]", i+1, f.Key, f.Count, float64(f.Count)/float64(total))
+		}
+	}
+}
@@ -1,10 +1,15 @@
 package data
 
 import (
+	"fmt"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
 	"github.com/singularity-data/tpch-bench/pkg/util"
 	"math"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 type StringBaseGenerator struct {
@@ -146,8 +151,9 @@ var onceTextPool sync.Once
 var textPoolSingleton *TextPool
 
 const (
-	DefaultTextPoolSize int = 300 * 1024 * 1024
-	MaxSentenceLength   int = 256
+	MinTextPoolSize   int   = 64 * 1024
+	MaxSentenceLength int   = 256
+	TextPoolSeed      int64 = 933588178
 )
 
 type TextPool struct {
@@ -155,26 +161,109 @@ type TextPool struct {
 	size  int
 }
 
+// GetTextPool builds the pool of configs.TextPoolSize bytes on first call.
+// If configs.TextPoolCacheDir is set, the pool is loaded from the cache file
+// and the built pool is saved there for later runs
 func GetTextPool() *TextPool {
 	onceTextPool.Do(func() {
-		randomInt := NewRandomInt(933588178, math.MaxInt32)
+		size := configs.TextPoolSize
+		if size < MinTextPoolSize {
+			util.LogInfo("text pool size %d is too small, use %d instead", size, MinTextPoolSize)
+			size = MinTextPoolSize
+		}
 		distManager := GetDistributionManager()
-		buffer := NewBytesBuilder(DefaultTextPoolSize + MaxSentenceLength)
-		for buffer.GetSize() < DefaultTextPoolSize {
-			err := generateSentence(distManager, randomInt, buffer)
-			if err != nil {
-				util.LogErr(err.Error())
-			}
+		if configs.TextPoolCacheDir == "" {
+			textPoolSingleton = NewTextPool(distManager, size)
+			return
 		}
-		buffer.Erase(buffer.GetSize() - DefaultTextPoolSize)
-		textPoolSingleton = &TextPool{
-			buffer.GetBytes(),
-			buffer.GetSize(),
+
+		path := TextPoolCachePath(configs.TextPoolCacheDir, distManager, size)
+		pool, err := OpenTextPool(path, size)
+		if err == nil {
+			util.LogInfo("load text pool from %s", path)
+			textPoolSingleton = pool
+			return
 		}
+		textPoolSingleton = NewTextPool(distManager, size)
+		err = textPoolSingleton.Save(path)
+		if err != nil {
+			util.LogErr("save text pool error: %s", err.Error())
+			return
+		}
+		util.LogInfo("save text pool to %s", path)
 	})
 	return textPoolSingleton
 }
 
+func NewTextPool(distManager *DistributionManager, size int) *TextPool {
+	var timer = time.Now()
+	randomInt := NewRandomInt(TextPoolSeed, math.MaxInt32)
+	buffer := NewBytesBuilder(size + MaxSentenceLength)
+	for buffer.GetSize() < size {
+		err := generateSentence(distManager, randomInt, buffer)
+		if err != nil {
+			util.LogErr(err.Error())
+		}
+	}
+	buffer.Erase(buffer.GetSize() - size)
+	util.LogInfo("build text pool of %d bytes takes %f seconds", size, time.Now().Sub(timer).Seconds())
+	return &TextPool{
+		buffer.GetBytes(),
+		buffer.GetSize(),
+	}
+}
+
+// TextPoolCachePath the pool is identified by its seed, its size and the distribution file
+func TextPoolCachePath(dir string, distManager *DistributionManager, size int) string {
+	name := fmt.Sprintf("textpool-%d-%d-%s.bin", PerturbSeed(TextPoolSeed), size, distManager.Checksum()[:16])
+	return filepath.Join(dir, name)
+}
+
+// OpenTextPool maps a saved pool into memory
+func OpenTextPool(path string, size int) (*TextPool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() != int64(size) {
+		return nil, util.Errorf("text pool %s has %d bytes, expected %d", path, info.Size(), size)
+	}
+	inner, err := mapFile(f, size)
+	if err != nil {
+		return nil, err
+	}
+	return &TextPool{
+		inner,
+		size,
+	}, nil
+}
+
+// Save writes the pool to a temporary file first, so concurrent runs never see a partial pool
+func (t *TextPool) Save(path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(t.inner[:t.size])
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
 func (t *TextPool) GetSize() int {
 	return t.size
 }
@@ -236,7 +325,7 @@ func generateSentence(distManager *DistributionManager, randomInt *RandomInt, bu
 			}
 			buffer.Append(termi)
 		default:
-			return util.Errorf("Unknown word in grammar syntax: %s", syntax[i])
+			return util.Errorf("Unknown word in grammar syntax: %c", syntax[i])
 		}
 		if buffer.GetLast() != ' ' {
 			buffer.Append(" ")
@@ -266,7 +355,7 @@ func generateVerb(distManager *DistributionManager, randomInt *RandomInt, buffer
 		case 'X':
 			source, err = distManager.GetDistribution("auxillaries")
 		default:
-			return util.Errorf("Unknown word in vp syntax: %s", syntax[i])
+			return util.Errorf("Unknown word in vp syntax: %c", syntax[i])
 		}
 		if err != nil {
 			return err
@@ -311,7 +400,7 @@ func generateNoun(distManager *DistributionManager, randomInt *RandomInt, buffer
 		case ' ':
 			continue
 		default:
-			return util.Errorf("Unknown word in np syntax: %s", syntax[i])
+			return util.Errorf("Unknown word in np syntax: %c", syntax[i])
 		}
 		if err != nil {
 			return err
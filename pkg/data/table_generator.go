@@ -1,10 +1,16 @@
 package data
 
-import "github.com/singularity-data/tpch-bench/pkg/configs"
+import (
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"time"
+)
 
 type TableGeneratorConfig struct {
 	ScaleFactor   float64
 	TablePartsMap map[configs.TpchTable]int
+	EventClock    *EventClock         // nil if rows carry no event time
+	Unbounded     []configs.TpchTable // orders or lineitem that keep generating beyond the scale factor
 }
 
 // TableGenerator every specific table generator could generate data concurrently
@@ -48,12 +54,14 @@ func NewTableGenerator(config *TableGeneratorConfig) *TableGenerator {
 	t.OrderGen = make([]*OrderGenerator, orderParts)
 	for i := 0; i < orderParts; i++ {
 		t.OrderGen[i] = NewOrderGenerator(config.ScaleFactor, i+1, orderParts)
+		t.OrderGen[i].clock = config.EventClock
 	}
 
 	lineItemParts := config.TablePartsMap[configs.LineItem]
 	t.LineItemGen = make([]*LineItemGenerator, lineItemParts)
 	for i := 0; i < lineItemParts; i++ {
 		t.LineItemGen[i] = NewLineItemGenerator(config.ScaleFactor, i+1, lineItemParts)
+		t.LineItemGen[i].clock = config.EventClock
 	}
 
 	customerParts := config.TablePartsMap[configs.Customer]
@@ -80,6 +88,19 @@ func NewTableGenerator(config *TableGeneratorConfig) *TableGenerator {
 		t.PartSuppGen[i] = NewPartSuppGenerator(config.ScaleFactor, i+1, partSuppParts)
 	}
 
+	for _, table := range config.Unbounded {
+		switch table {
+		case configs.Orders:
+			for _, gen := range t.OrderGen {
+				gen.SetUnbounded()
+			}
+		case configs.LineItem:
+			for _, gen := range t.LineItemGen {
+				gen.SetUnbounded()
+			}
+		}
+	}
+
 	t.NationsGen = make([]*NationGenerator, 1)
 	t.NationsGen[0] = NewNationGenerator()
 	t.RegionsGen = make([]*RegionGenerator, 1)
@@ -88,6 +109,39 @@ func NewTableGenerator(config *TableGeneratorConfig) *TableGenerator {
 	return t
 }
 
+// TableRowCount rows of a part (counting from 1) of a bounded table, the same as Capacity() of its generator,
+// but without building the generator and its text pool
+func TableRowCount(table configs.TpchTable, scaleFactor float64, part int, partCnt int) (int64, error) {
+	switch table {
+	case configs.LineItem:
+		return CalcuRowCnt(OrderScaleBase, scaleFactor, part, partCnt) * 4, nil
+	case configs.Orders:
+		return CalcuRowCnt(OrderScaleBase, scaleFactor, part, partCnt), nil
+	case configs.Customer:
+		return CalcuRowCnt(CustomerScaleBase, scaleFactor, part, partCnt), nil
+	case configs.Part:
+		return CalcuRowCnt(PartScaleBase, scaleFactor, part, partCnt), nil
+	case configs.Supplier:
+		return CalcuRowCnt(SupplierScaleBase, scaleFactor, part, partCnt), nil
+	case configs.PartSupp:
+		return CalcuRowCnt(PartScaleBase, scaleFactor, part, partCnt) * 4, nil
+	case configs.Nation:
+		return distributionSize("nations")
+	case configs.Region:
+		return distributionSize("regions")
+	default:
+		return 0, util.Errorf("unknown table: %s", table)
+	}
+}
+
+func distributionSize(name string) (int64, error) {
+	dist, err := GetDistributionManager().GetDistribution(name)
+	if err != nil {
+		return 0, err
+	}
+	return int64(dist.Size()), nil
+}
+
 func (t *TableGenerator) GetSingleTableGenerator(table configs.TpchTable, i int) JsonIterable {
 	switch table {
 	case configs.LineItem:
@@ -116,3 +170,15 @@ type JsonIterable interface {
 	Next() []byte
 	Capacity() int64
 }
+
+// EventTimed Make generator able to report event time of the last generated item,
+// zero time means the item carries no event time
+type EventTimed interface {
+	EventTime() time.Time
+}
+
+// RowIndexed Make generator able to report the row id of the last generated item,
+// row id of a lineitem is the row id of its order
+type RowIndexed interface {
+	LastRowId() int64
+}
@@ -0,0 +1,111 @@
+package exec
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// CausalGate couples the orders producer and the lineitem producer of the same part.
+// A lineitem is sent only after its order has been flushed to kafka,
+// and the orders producer may not run ahead of the lineitem producer by more than maxSkew orders.
+// Orders are identified by their sequence number within the part, both producers see orders in the same sequence
+type CausalGate struct {
+	mutex         sync.Mutex
+	cond          *sync.Cond
+	maxSkew       int64
+	ordersFlushed int64 // sequence of the last order flushed
+	lineItemOrder int64 // sequence of the order of the last lineitem sent
+	stats         CausalStats
+}
+
+type CausalStats struct {
+	MaxSkew      int64         // max orders flushed ahead of the lineitem being sent
+	LineItemWait time.Duration // time lineitem producer waits for orders
+	OrdersWait   time.Duration // time orders producer waits for lineitem
+}
+
+func (s *CausalStats) Merge(other *CausalStats) {
+	if other.MaxSkew > s.MaxSkew {
+		s.MaxSkew = other.MaxSkew
+	}
+	s.LineItemWait += other.LineItemWait
+	s.OrdersWait += other.OrdersWait
+}
+
+func NewCausalGate(maxSkew int64) *CausalGate {
+	g := &CausalGate{
+		maxSkew: maxSkew,
+	}
+	g.cond = sync.NewCond(&g.mutex)
+	return g
+}
+
+// OrdersMayProceed whether the order could be sent without waiting for lineitem
+func (g *CausalGate) OrdersMayProceed(orderSeq int64) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return orderSeq-g.lineItemOrder <= g.maxSkew
+}
+
+// WaitLineItems blocks until the order is within maxSkew of the lineitem stream,
+// caller must have published all flushed orders, or lineitem producer may wait for it forever
+func (g *CausalGate) WaitLineItems(orderSeq int64) {
+	start := time.Now()
+	g.mutex.Lock()
+	for orderSeq-g.lineItemOrder > g.maxSkew {
+		g.cond.Wait()
+	}
+	g.stats.OrdersWait += time.Now().Sub(start)
+	g.mutex.Unlock()
+}
+
+func (g *CausalGate) PublishOrders(orderSeq int64) {
+	g.mutex.Lock()
+	if orderSeq > g.ordersFlushed {
+		g.ordersFlushed = orderSeq
+	}
+	g.mutex.Unlock()
+	g.cond.Broadcast()
+}
+
+// WaitOrders blocks until the order of the lineitem has been flushed
+func (g *CausalGate) WaitOrders(orderSeq int64) {
+	start := time.Now()
+	g.mutex.Lock()
+	for g.ordersFlushed < orderSeq {
+		g.cond.Wait()
+	}
+	g.stats.LineItemWait += time.Now().Sub(start)
+	if g.ordersFlushed != math.MaxInt64 && g.ordersFlushed-orderSeq > g.stats.MaxSkew {
+		g.stats.MaxSkew = g.ordersFlushed - orderSeq
+	}
+	changed := orderSeq > g.lineItemOrder
+	if changed {
+		g.lineItemOrder = orderSeq
+	}
+	g.mutex.Unlock()
+	if changed {
+		g.cond.Broadcast()
+	}
+}
+
+// CloseOrders the orders producer finishes, lineitem never waits for orders again
+func (g *CausalGate) CloseOrders() {
+	g.PublishOrders(math.MaxInt64)
+}
+
+// CloseLineItems the lineitem producer finishes, orders never wait for lineitem again
+func (g *CausalGate) CloseLineItems() {
+	g.mutex.Lock()
+	g.lineItemOrder = math.MaxInt64 - g.maxSkew
+	g.mutex.Unlock()
+	g.cond.Broadcast()
+}
+
+func (g *CausalGate) Stats() *CausalStats {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	stats := g.stats
+	return &stats
+}
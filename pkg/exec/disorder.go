@@ -0,0 +1,152 @@
+package exec
+
+import (
+	"container/heap"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"math/rand"
+	"time"
+)
+
+// DisorderTick granularity at which delayed rows are released
+const DisorderTick = 10 * time.Millisecond
+
+type heldMessage struct {
+	msg     *kafka.Message
+	held    time.Time
+	release time.Time
+	late    bool
+}
+
+// heldHeap min-heap of held messages ordered by release time
+type heldHeap []*heldMessage
+
+func (h heldHeap) Len() int            { return len(h) }
+func (h heldHeap) Less(i, j int) bool  { return h[i].release.Before(h[j].release) }
+func (h heldHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *heldHeap) Push(x interface{}) { *h = append(*h, x.(*heldMessage)) }
+func (h *heldHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+type DisorderStats struct {
+	Rows       int64         // rows admitted
+	Delayed    int64         // rows delayed within DisorderMaxDelay
+	Late       int64         // rows delayed by LateDelay
+	Shuffled   int64         // rows sent in shuffled windows
+	TotalDelay time.Duration // sum of delays of delayed and late rows
+	MaxDelay   time.Duration
+}
+
+func (s *DisorderStats) Merge(other *DisorderStats) {
+	s.Rows += other.Rows
+	s.Delayed += other.Delayed
+	s.Late += other.Late
+	s.Shuffled += other.Shuffled
+	s.TotalDelay += other.TotalDelay
+	if other.MaxDelay > s.MaxDelay {
+		s.MaxDelay = other.MaxDelay
+	}
+}
+
+// Disorder sends rows of one producer out of order:
+// some rows are held back for a bounded random time, some are held back beyond the watermark,
+// and the remaining rows are shuffled within a window
+type Disorder struct {
+	random *rand.Rand
+	held   heldHeap
+	window []*kafka.Message
+	stats  DisorderStats
+}
+
+// NewDisorder returns nil if disorder is not configured
+func NewDisorder(seed int64) *Disorder {
+	if !configs.DisorderEnabled() {
+		return nil
+	}
+	return &Disorder{
+		random: rand.New(rand.NewSource(seed)),
+		held:   make(heldHeap, 0),
+		window: make([]*kafka.Message, 0),
+	}
+}
+
+// Admit returns messages that could be sent right now
+func (d *Disorder) Admit(msg *kafka.Message, now time.Time) []*kafka.Message {
+	d.stats.Rows++
+	p := d.random.Float64()
+	if p < configs.LateFraction {
+		heap.Push(&d.held, &heldMessage{msg, now, now.Add(configs.LateDelay), true})
+		return nil
+	}
+	if p < configs.LateFraction+configs.DisorderFraction && configs.DisorderMaxDelay > 0 {
+		delay := time.Duration(d.random.Int63n(int64(configs.DisorderMaxDelay)) + 1)
+		heap.Push(&d.held, &heldMessage{msg, now, now.Add(delay), false})
+		return nil
+	}
+	if configs.ShuffleWindow <= 1 {
+		return []*kafka.Message{msg}
+	}
+	d.window = append(d.window, msg)
+	if len(d.window) < configs.ShuffleWindow {
+		return nil
+	}
+	return d.FlushWindow()
+}
+
+// FlushWindow returns rows of current window in random order
+func (d *Disorder) FlushWindow() []*kafka.Message {
+	if len(d.window) == 0 {
+		return nil
+	}
+	window := d.window
+	d.random.Shuffle(len(window), func(i, j int) {
+		window[i], window[j] = window[j], window[i]
+	})
+	d.stats.Shuffled += int64(len(window))
+	d.window = make([]*kafka.Message, 0, configs.ShuffleWindow)
+	return window
+}
+
+// Release returns held messages whose release time has come
+func (d *Disorder) Release(now time.Time) []*kafka.Message {
+	return d.release(now, false)
+}
+
+// Drain returns all held messages regardless of their release time, used when the producer stops early
+func (d *Disorder) Drain(now time.Time) []*kafka.Message {
+	return append(d.FlushWindow(), d.release(now, true)...)
+}
+
+func (d *Disorder) release(now time.Time, all bool) []*kafka.Message {
+	msgs := make([]*kafka.Message, 0)
+	for len(d.held) > 0 && (all || !d.held[0].release.After(now)) {
+		h := heap.Pop(&d.held).(*heldMessage)
+		delay := now.Sub(h.held)
+		if h.late {
+			d.stats.Late++
+		} else {
+			d.stats.Delayed++
+		}
+		d.stats.TotalDelay += delay
+		if delay > d.stats.MaxDelay {
+			d.stats.MaxDelay = delay
+		}
+		msgs = append(msgs, h.msg)
+	}
+	return msgs
+}
+
+// Pending number of messages not released yet
+func (d *Disorder) Pending() int {
+	return len(d.held) + len(d.window)
+}
+
+func (d *Disorder) Stats() *DisorderStats {
+	return &d.stats
+}
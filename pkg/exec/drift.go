@@ -0,0 +1,47 @@
+package exec
+
+import (
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/data"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"time"
+)
+
+// runDriftSchedule applies drift points at their offsets since it's called, until done is closed.
+// Distributions in the schedule start from their loaded weights, and get them back once done is closed,
+// so that drift never leaks into later sends
+func runDriftSchedule(points []*configs.DriftPoint, done <-chan struct{}) {
+	start := time.Now()
+	distManager := data.GetDistributionManager()
+	resetDrift(distManager, points)
+	defer resetDrift(distManager, points)
+	for _, point := range points {
+		timer := time.NewTimer(time.Until(start.Add(point.At)))
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		dist, err := distManager.GetDistribution(point.Distribution)
+		if err != nil {
+			util.LogErr("drift at %v: %s", point.At, err.Error())
+			continue
+		}
+		if err := dist.Drift(point.Weights); err != nil {
+			util.LogErr("drift at %v: %s", point.At, err.Error())
+			continue
+		}
+		util.LogInfo("------Drift distribution %s at %v: %v------", point.Distribution, point.At, point.Weights)
+	}
+	// the last weights hold until production finishes
+	<-done
+}
+
+func resetDrift(distManager *data.DistributionManager, points []*configs.DriftPoint) {
+	for _, point := range points {
+		if dist, err := distManager.GetDistribution(point.Distribution); err == nil {
+			dist.ResetDrift()
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package exec
+
+import (
+	"bufio"
+	"context"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"os"
+	"path/filepath"
+)
+
+// WriteRowsToFiles writes rows of all tables of the query as json lines to <dir>/<table>.json, without kafka
+func (k *QueryKafkaExecutor) WriteRowsToFiles(ctx context.Context, dir string) error {
+	if len(k.unboundedTables()) > 0 {
+		return util.Errorf("unbounded tables could not be written to files")
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	for _, cf := range k.producerCfs {
+		path := filepath.Join(dir, string(cf.Table)+".json")
+		rows, err := k.writeTable(ctx, path, cf.Table, cf.Nums)
+		if err != nil {
+			return util.Errorf("write %s error: %s", path, err.Error())
+		}
+		util.LogInfo("%d rows of %s are written to %s", rows, cf.Table, path)
+	}
+	return nil
+}
+
+func (k *QueryKafkaExecutor) writeTable(ctx context.Context, path string, table configs.TpchTable, parts int) (int64, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	rows := int64(0)
+	for i := 0; i < parts; i++ {
+		gen := k.tableGen.GetSingleTableGenerator(table, i)
+		for j := int64(0); j < gen.Capacity(); j++ {
+			if j%cancelCheckRows == 0 && ctx.Err() != nil {
+				return rows, ctx.Err()
+			}
+			if _, err := writer.Write(gen.Next()); err != nil {
+				return rows, err
+			}
+			if err := writer.WriteByte('\n'); err != nil {
+				return rows, err
+			}
+			rows++
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return rows, err
+	}
+	return rows, file.Close()
+}
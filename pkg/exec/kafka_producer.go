@@ -1,21 +1,44 @@
 package exec
 
 import (
+	"bytes"
+	"context"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/singularity-data/tpch-bench/pkg/configs"
 	"github.com/singularity-data/tpch-bench/pkg/data"
 	"github.com/singularity-data/tpch-bench/pkg/util"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// cancelCheckRows rows produced between two checks of cancellation
+const cancelCheckRows = 1024
+
+// nullRow is generated once a generator runs out of rows, ex: lineitem of all orders are generated before its capacity
+var nullRow = []byte("null")
+
 type KafkaProducer struct {
 	id       int
 	topic    string
 	rate     int64
 	sendType string
 	curIdx   int64
+	sent     int64 // curIdx read by other goroutines, accessed atomically
+	rows     int64 // rows generated, less than sent if the generator runs out before its capacity, accessed atomically
 	producer *kafka.Producer
 	dataRows data.JsonIterable
+	disorder *Disorder   // nil if rows are sent in order
+	causal   *CausalGate // nil if not in causal mode
+	// sequence and row id of the current order in causal mode
+	causalSeq   int64
+	causalRowId int64
+	timeline    *InputTimeline // nil if flush times are not tracked
+	failed      int64          // rows failed to be produced or not flushed in time
+	log         *util.Logger
+	progress    *util.Throttle // limits progress lines
+	closeMutex  sync.Mutex     // the queue is not read after the producer is closed
+	closed      bool
 }
 
 func NewKafkaProducer(id int, cf *configs.KafkaProducerConfig, dataRows data.JsonIterable) (*KafkaProducer, error) {
@@ -30,14 +53,30 @@ func NewKafkaProducer(id int, cf *configs.KafkaProducerConfig, dataRows data.Jso
 	if err != nil {
 		return nil, err
 	}
+	var disorder *Disorder
+	if cf.Type == configs.RealTime {
+		disorder = NewDisorder(int64(id) + 1)
+	}
 	return &KafkaProducer{
 		id,
 		string(cf.Table),
 		int64(cf.Rate),
 		cf.Type,
 		0,
+		0,
+		0,
 		producer,
 		dataRows,
+		disorder,
+		nil,
+		0,
+		0,
+		nil,
+		0,
+		util.With("producer", id, "table", cf.Table),
+		util.NewThrottle(configs.ProducerLogInterval),
+		sync.Mutex{},
+		false,
 	}, nil
 }
 
@@ -45,42 +84,202 @@ func (k *KafkaProducer) Size() int64 {
 	return k.dataRows.Capacity()
 }
 
+// Sent number of rows produced so far, it's safe to call while producing
+func (k *KafkaProducer) Sent() int64 {
+	sent := atomic.LoadInt64(&k.sent)
+	if sent > k.dataRows.Capacity() {
+		return k.dataRows.Capacity()
+	}
+	return sent
+}
+
+// Rows number of rows generated so far, messages sent after the generator runs out carry no row
+func (k *KafkaProducer) Rows() int64 {
+	return atomic.LoadInt64(&k.rows)
+}
+
+// QueueLen messages waiting in the queue of librdkafka to be delivered, 0 once the producer is closed
+func (k *KafkaProducer) QueueLen() int {
+	k.closeMutex.Lock()
+	defer k.closeMutex.Unlock()
+	if k.closed {
+		return 0
+	}
+	return k.producer.Len()
+}
+
+func (k *KafkaProducer) close() {
+	k.closeMutex.Lock()
+	defer k.closeMutex.Unlock()
+	k.closed = true
+	k.producer.Close()
+}
+
+// Failed number of rows that are not delivered to kafka
+func (k *KafkaProducer) Failed() int64 {
+	return k.failed
+}
+
 func (k *KafkaProducer) Events() chan kafka.Event {
 	return k.producer.Events()
 }
 
-func (k *KafkaProducer) WriteRowsToKafka() {
+// WriteRowsToKafka stops producing when ctx is canceled, rows produced so far are flushed before it returns
+func (k *KafkaProducer) WriteRowsToKafka(ctx context.Context) {
 	if k.sendType == configs.Batch {
 		k.rate = k.dataRows.Capacity()
-		k.produce()
+		k.produce(ctx)
 	} else {
 		timer := time.NewTicker(1 * time.Second)
-		for k.curIdx < k.dataRows.Capacity() {
+		var release <-chan time.Time
+		if k.disorder != nil {
+			releaseTimer := time.NewTicker(DisorderTick)
+			defer releaseTimer.Stop()
+			release = releaseTimer.C
+		}
+		var deadline <-chan time.Time
+		if configs.StreamDuration > 0 {
+			deadline = time.After(configs.StreamDuration)
+		}
+		expired := false
+		for (k.curIdx < k.dataRows.Capacity() && !expired) || k.pending() > 0 {
 			select {
 			case <-timer.C:
-				k.produce()
+				if !expired {
+					k.produce(ctx)
+				}
+			case now := <-release:
+				k.sendMessages(k.disorder.Release(now))
+			case <-deadline:
+				k.log.Info("stops after %v", configs.StreamDuration)
+				expired = true
+			case <-ctx.Done():
+				k.log.Info("interrupted")
+				expired = true
+				if k.disorder != nil {
+					k.sendMessages(k.disorder.Drain(time.Now()))
+				}
 			}
 		}
 		timer.Stop()
+		k.flush()
 	}
-	k.producer.Close()
+	if k.causal != nil {
+		if k.topic == string(configs.Orders) {
+			k.causal.CloseOrders()
+		} else {
+			k.causal.CloseLineItems()
+		}
+	}
+	k.close()
+}
+
+// DisorderStats returns nil if rows are sent in order
+func (k *KafkaProducer) DisorderStats() *DisorderStats {
+	if k.disorder == nil {
+		return nil
+	}
+	return k.disorder.Stats()
 }
 
-func (k *KafkaProducer) produce() {
+func (k *KafkaProducer) pending() int {
+	if k.disorder == nil {
+		return 0
+	}
+	return k.disorder.Pending()
+}
+
+func (k *KafkaProducer) sendMessages(msgs []*kafka.Message) {
+	for _, msg := range msgs {
+		err := k.producer.Produce(msg, nil)
+		if err != nil {
+			k.log.Error(err.Error())
+			k.failed++
+		}
+	}
+}
+
+// flush waits for produced rows to be delivered at most configs.FlushTimeout
+func (k *KafkaProducer) flush() {
+	remaining := k.producer.Flush(int(configs.FlushTimeout.Milliseconds()))
+	if remaining > 0 {
+		k.log.Error("%d events are not flushed within %v", remaining, configs.FlushTimeout)
+		k.failed += int64(remaining)
+	}
+}
+
+func (k *KafkaProducer) produce(ctx context.Context) {
 	if k.curIdx >= k.dataRows.Capacity() {
 		return
 	}
 	var produceTimer = time.Now()
-	for i := k.curIdx; i < k.curIdx+k.rate && i < k.dataRows.Capacity(); i++ {
-		err := k.producer.Produce(&kafka.Message{
+	end := k.curIdx + k.rate
+	if end > k.dataRows.Capacity() {
+		end = k.dataRows.Capacity()
+	}
+	i := k.curIdx
+	rows := int64(0)
+	for ; i < end; i++ {
+		if (i-k.curIdx)%cancelCheckRows == 0 && ctx.Err() != nil {
+			break
+		}
+		msg := &kafka.Message{
 			TopicPartition: kafka.TopicPartition{Topic: &k.topic, Partition: kafka.PartitionAny},
 			Value:          k.dataRows.Next(),
-		}, nil)
+		}
+		if !bytes.Equal(msg.Value, nullRow) {
+			rows++
+		}
+		if timed, ok := k.dataRows.(data.EventTimed); ok {
+			msg.Timestamp = timed.EventTime()
+		}
+		if k.causal != nil {
+			k.waitCausal()
+		}
+		if k.disorder != nil {
+			k.sendMessages(k.disorder.Admit(msg, time.Now()))
+			continue
+		}
+		err := k.producer.Produce(msg, nil)
 		if err != nil {
-			util.LogErr(err.Error())
+			k.log.Error(err.Error())
+			k.failed++
+		}
+	}
+	if k.disorder != nil {
+		k.sendMessages(k.disorder.FlushWindow())
+	}
+	k.log.Debug("%d events take %f seconds", i-k.curIdx, time.Now().Sub(produceTimer).Seconds())
+	k.curIdx = i
+	atomic.StoreInt64(&k.sent, i)
+	atomic.AddInt64(&k.rows, rows)
+	if k.progress.Allow() {
+		k.log.Debug("%d/%d rows sent", k.Sent(), k.dataRows.Capacity())
+	}
+	k.flush()
+	if k.timeline != nil {
+		k.timeline.Add(time.Now())
+	}
+	if k.causal != nil && k.topic == string(configs.Orders) {
+		k.causal.PublishOrders(k.causalSeq)
+	}
+}
+
+// waitCausal is called before the last generated row is sent
+func (k *KafkaProducer) waitCausal() {
+	rowId := k.dataRows.(data.RowIndexed).LastRowId()
+	if rowId != k.causalRowId {
+		k.causalRowId = rowId
+		k.causalSeq++
+	}
+	if k.topic == string(configs.Orders) {
+		if !k.causal.OrdersMayProceed(k.causalSeq) {
+			// lineitem may be waiting for orders produced but not flushed yet
+			k.flush()
+			k.causal.PublishOrders(k.causalSeq - 1)
+			k.causal.WaitLineItems(k.causalSeq)
 		}
+	} else {
+		k.causal.WaitOrders(k.causalSeq)
 	}
-	util.LogInfo("producer[%d] %d events takes %f seconds", k.id, k.rate, time.Now().Sub(produceTimer).Seconds())
-	k.curIdx += k.rate
-	k.producer.Flush(10 * 1000)
 }
@@ -0,0 +1,69 @@
+package exec
+
+import (
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/data"
+	"math"
+	"time"
+)
+
+// ProducerPlan producers of a table as a run would create them
+type ProducerPlan struct {
+	Table configs.TpchTable
+	Type  string // realtime or batch
+	Nums  int
+	Rate  int   // rows per second of each producer, -1 in batch
+	Rows  int64 // rows of all parts, math.MaxInt64 if unbounded
+}
+
+// RunPlan what a run would send, it's computed without building generators or connecting to kafka
+type RunPlan struct {
+	Producers []*ProducerPlan
+	Duration  time.Duration // expected time of realtime production, -1 if unbounded, 0 if nothing is streamed
+}
+
+// Plan the producers that Prepare would create, generators and their text pool are not built
+func (k *QueryKafkaExecutor) Plan() (*RunPlan, error) {
+	_, err := k.prepare()
+	if err != nil {
+		return nil, err
+	}
+	unbounded := make(map[configs.TpchTable]bool)
+	for _, table := range k.unboundedTables() {
+		unbounded[table] = true
+	}
+
+	plan := &RunPlan{
+		Producers: make([]*ProducerPlan, 0),
+	}
+	var deadline time.Time
+	now := time.Now()
+	if configs.StreamDuration > 0 {
+		deadline = now.Add(configs.StreamDuration)
+	}
+	for _, cf := range k.producerCfs {
+		producer := &ProducerPlan{cf.Table, cf.Type, cf.Nums, cf.Rate, 0}
+		if unbounded[cf.Table] {
+			producer.Rows = math.MaxInt64
+		} else {
+			for i := 0; i < cf.Nums; i++ {
+				rows, err := data.TableRowCount(cf.Table, k.config.ScaleFactor, i+1, cf.Nums)
+				if err != nil {
+					return nil, err
+				}
+				producer.Rows += rows
+			}
+		}
+		plan.Producers = append(plan.Producers, producer)
+
+		// the slowest realtime table decides when production finishes
+		if cf.Type != configs.RealTime || plan.Duration < 0 {
+			continue
+		}
+		eta := EstimateETA(producer.Rows, float64(cf.Rate*cf.Nums), deadline, now)
+		if eta < 0 || eta > plan.Duration {
+			plan.Duration = eta
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,148 @@
+package exec
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/data"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProbeMV side mv that probe rows flow through
+const ProbeMV string = "tpch_probe"
+
+func ProbeMVStatement() string {
+	return fmt.Sprintf("create materialized view %s as select l_orderkey from lineitem where l_orderkey < 0", ProbeMV)
+}
+
+type ProbeStats struct {
+	Sent      int64
+	Lost      int64           // probes not visible before the prober stops
+	Latencies []time.Duration // time from a probe acknowledged by kafka to visible in the probe mv
+}
+
+// Prober measures end-to-end freshness: it sends probe rows to the lineitem topic
+// and polls the probe mv for their appearance
+type Prober struct {
+	producer *kafka.Producer
+	executor *SQLExecutor
+	topic    string
+	nextId   int64
+	pending  map[int64]time.Time // probe id -> publish time of probes not visible yet
+	stats    ProbeStats
+}
+
+func NewProber(db *sql.DB) (*Prober, error) {
+	producer, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers":   configs.KafkaAddr,
+		"go.delivery.reports": false,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Prober{
+		producer,
+		NewSQLExecutor(db),
+		string(configs.LineItem),
+		1,
+		make(map[int64]time.Time),
+		ProbeStats{},
+	}, nil
+}
+
+// Run sends a probe every configs.ProbeInterval until done is closed,
+// then waits at most configs.ProbeTimeout for pending probes to be visible
+func (p *Prober) Run(ctx context.Context, done <-chan struct{}) *ProbeStats {
+	defer p.producer.Close()
+	sendTicker := time.NewTicker(configs.ProbeInterval)
+	defer sendTicker.Stop()
+	pollTicker := time.NewTicker(configs.ProbePollInterval)
+	defer pollTicker.Stop()
+
+	sending := sendTicker.C
+	var timeout <-chan time.Time
+	for sending != nil || len(p.pending) > 0 {
+		select {
+		case <-ctx.Done():
+			sending = nil
+			p.pending = make(map[int64]time.Time)
+		case <-done:
+			done = nil
+			sending = nil
+			timeout = time.After(configs.ProbeTimeout)
+		case <-timeout:
+			util.LogInfo("%d probes are not visible within %v", len(p.pending), configs.ProbeTimeout)
+			p.pending = make(map[int64]time.Time)
+		case <-sending:
+			p.send()
+		case now := <-pollTicker.C:
+			p.poll(ctx, now)
+		}
+	}
+	p.stats.Lost = p.stats.Sent - int64(len(p.stats.Latencies))
+	return &p.stats
+}
+
+func (p *Prober) send() {
+	id := p.nextId
+	p.nextId++
+	err := p.producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &p.topic, Partition: kafka.PartitionAny},
+		Value:          data.NewProbeLineItem(id, time.Now()),
+	}, nil)
+	if err != nil {
+		util.LogErr("send probe error: %s", err.Error())
+		return
+	}
+	if p.producer.Flush(int(configs.FlushTimeout.Milliseconds())) > 0 {
+		util.LogErr("probe[%d] is not flushed within %v", id, configs.FlushTimeout)
+		return
+	}
+	p.pending[id] = time.Now()
+	p.stats.Sent++
+}
+
+// poll probes visible at `now` are the ones returned by a query started at `now`
+func (p *Prober) poll(ctx context.Context, now time.Time) {
+	if len(p.pending) == 0 {
+		return
+	}
+	minId, maxId := int64(-1), int64(-1)
+	for id := range p.pending {
+		if minId == -1 || id < minId {
+			minId = id
+		}
+		if id > maxId {
+			maxId = id
+		}
+	}
+	result, err := p.executor.QueryResult(ctx, fmt.Sprintf("select l_orderkey from %s where l_orderkey >= %d and l_orderkey <= %d",
+		ProbeMV, -maxId, -minId))
+	if err != nil {
+		if ctx.Err() == nil {
+			util.LogErr("poll probes error: %s", err.Error())
+		}
+		return
+	}
+	for _, field := range strings.Fields(result) {
+		key, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			continue
+		}
+		published, ok := p.pending[-key]
+		if !ok {
+			continue
+		}
+		delete(p.pending, -key)
+		latency := now.Sub(published)
+		if latency < 0 {
+			latency = 0
+		}
+		p.stats.Latencies = append(p.stats.Latencies, latency)
+	}
+}
@@ -0,0 +1,160 @@
+package exec
+
+import (
+	"fmt"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// TableProgress rows of a table sent by all its producers
+type TableProgress struct {
+	Table    configs.TpchTable
+	Sent     int64
+	Capacity int64 // math.MaxInt64 if unbounded
+}
+
+// ProgressSnapshot progress of all producers at a time
+type ProgressSnapshot struct {
+	Tables     []*TableProgress // in the order of producers
+	Sent       int64
+	Capacity   int64   // math.MaxInt64 if any table is unbounded
+	Rate       float64 // rows per second since the previous snapshot
+	TargetRate int64   // sum of rates of realtime producers, 0 in batch
+	QueueLen   int     // messages waiting in librdkafka queues
+	ETA        time.Duration
+	MVRows     int64 // -1 if the mv is not sampled
+}
+
+// progressReporter logs a progress line aggregated across producers every configs.ProgressInterval
+type progressReporter struct {
+	producers []*KafkaProducer
+	mvRows    *int64
+	deadline  time.Time // producers stop at the deadline, zero if they stop when exhausted
+	lastSent  int64
+	lastTime  time.Time
+}
+
+func newProgressReporter(producers []*KafkaProducer, mvRows *int64) *progressReporter {
+	p := &progressReporter{
+		producers: producers,
+		mvRows:    mvRows,
+		lastTime:  time.Now(),
+	}
+	if configs.StreamDuration > 0 && len(producers) > 0 && producers[0].sendType == configs.RealTime {
+		p.deadline = time.Now().Add(configs.StreamDuration)
+	}
+	return p
+}
+
+// run logs progress until done is closed
+func (p *progressReporter) run(done <-chan struct{}) {
+	ticker := time.NewTicker(configs.ProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			util.LogInfo(p.snapshot(now).String())
+		}
+	}
+}
+
+func (p *progressReporter) snapshot(now time.Time) *ProgressSnapshot {
+	snapshot := &ProgressSnapshot{
+		Tables: make([]*TableProgress, 0),
+		MVRows: atomic.LoadInt64(p.mvRows),
+	}
+	tables := make(map[configs.TpchTable]*TableProgress)
+	for _, producer := range p.producers {
+		table := configs.TpchTable(producer.topic)
+		progress, ok := tables[table]
+		if !ok {
+			progress = &TableProgress{Table: table}
+			tables[table] = progress
+			snapshot.Tables = append(snapshot.Tables, progress)
+		}
+		progress.Sent += producer.Sent()
+		progress.Capacity = addCapacity(progress.Capacity, producer.dataRows.Capacity())
+		snapshot.QueueLen += producer.QueueLen()
+		if producer.sendType == configs.RealTime {
+			snapshot.TargetRate += producer.rate
+		}
+	}
+	for _, progress := range snapshot.Tables {
+		snapshot.Sent += progress.Sent
+		snapshot.Capacity = addCapacity(snapshot.Capacity, progress.Capacity)
+	}
+
+	if elapsed := now.Sub(p.lastTime).Seconds(); elapsed > 0 {
+		snapshot.Rate = float64(snapshot.Sent-p.lastSent) / elapsed
+	}
+	p.lastSent = snapshot.Sent
+	p.lastTime = now
+	snapshot.ETA = EstimateETA(snapshot.Capacity-snapshot.Sent, snapshot.Rate, p.deadline, now)
+	return snapshot
+}
+
+func addCapacity(a int64, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+// EstimateETA time until `remaining` rows are sent at `rate`, or until the deadline if it comes first.
+// -1 if it could not be estimated, ex: unbounded producers without a deadline
+func EstimateETA(remaining int64, rate float64, deadline time.Time, now time.Time) time.Duration {
+	eta := time.Duration(-1)
+	if remaining <= 0 {
+		eta = 0
+	} else if rate > 0 {
+		seconds := float64(remaining) / rate
+		if seconds < float64(math.MaxInt64/int64(time.Second)) {
+			eta = time.Duration(seconds * float64(time.Second))
+		}
+	}
+	if !deadline.IsZero() {
+		untilDeadline := deadline.Sub(now)
+		if untilDeadline < 0 {
+			untilDeadline = 0
+		}
+		if eta < 0 || untilDeadline < eta {
+			eta = untilDeadline
+		}
+	}
+	return eta
+}
+
+// String ex: progress: lineitem[1200000/6000000 20.0%] rate[298000/300000 rows/s] queue[1234] eta[16s] mv rows[10]
+func (s *ProgressSnapshot) String() string {
+	var builder strings.Builder
+	builder.WriteString("progress:")
+	for _, table := range s.Tables {
+		if table.Capacity == math.MaxInt64 {
+			builder.WriteString(fmt.Sprintf(" %s[%d/unbounded]", table.Table, table.Sent))
+			continue
+		}
+		builder.WriteString(fmt.Sprintf(" %s[%d/%d %.1f%%]", table.Table, table.Sent, table.Capacity,
+			100*float64(table.Sent)/math.Max(float64(table.Capacity), 1)))
+	}
+	if s.TargetRate > 0 {
+		builder.WriteString(fmt.Sprintf(" rate[%.0f/%d rows/s]", s.Rate, s.TargetRate))
+	} else {
+		builder.WriteString(fmt.Sprintf(" rate[%.0f rows/s]", s.Rate))
+	}
+	builder.WriteString(fmt.Sprintf(" queue[%d]", s.QueueLen))
+	if s.ETA >= 0 {
+		builder.WriteString(fmt.Sprintf(" eta[%v]", s.ETA.Round(time.Second)))
+	} else {
+		builder.WriteString(" eta[unknown]")
+	}
+	if s.MVRows >= 0 {
+		builder.WriteString(fmt.Sprintf(" mv rows[%d]", s.MVRows))
+	}
+	return builder.String()
+}
@@ -7,7 +7,9 @@ import (
 	"github.com/singularity-data/tpch-bench/pkg/data"
 	"github.com/singularity-data/tpch-bench/pkg/util"
 	"math"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,6 +19,10 @@ type QueryKafkaExecutor struct {
 	config      *configs.TpchBenchConfig
 	producerCfs []*configs.KafkaProducerConfig
 	tableGen    *data.TableGenerator
+	causalGates []*CausalGate  // one per part of orders and lineitem, nil if not in causal mode
+	timeline    *InputTimeline // flush times of realtime producers, nil if not tracked
+	mvRows      int64          // latest row count of the sampled mv shown in progress, -1 if not sampled
+	batchRows   map[configs.TpchTable]int64
 }
 
 func NewQueryKafkaExecutor(config *configs.TpchBenchConfig) *QueryKafkaExecutor {
@@ -24,10 +30,34 @@ func NewQueryKafkaExecutor(config *configs.TpchBenchConfig) *QueryKafkaExecutor
 		config,
 		make([]*configs.KafkaProducerConfig, 0),
 		nil,
+		nil,
+		nil,
+		-1,
+		make(map[configs.TpchTable]int64),
 	}
 }
 
+// AdminTopics creates or deletes topics of all tables, or ensures they exist.
+// Topics left by a previous run fail creating, sources created on them would read their rows again
 func AdminTopics(op string) error {
+	return adminTopics(op, Topics())
+}
+
+// Topics of all tables, they are created and deleted together
+func Topics() []string {
+	topics := make([]string, 0)
+	for _, table := range configs.TpchAllTables {
+		topics = append(topics, string(table))
+	}
+	return topics
+}
+
+// AdminSinkTopic creates or deletes the output topic of a sink
+func AdminSinkTopic(op string, topic string) error {
+	return adminTopics(op, []string{topic})
+}
+
+func adminTopics(op string, names []string) error {
 	util.LogInfo("------%s kafka topic------", op)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -38,24 +68,22 @@ func AdminTopics(op string) error {
 	if err != nil {
 		return util.Errorf("Create kafka admin client error: %s", err.Error())
 	}
+	defer client.Close()
 
 	var results []kafka.TopicResult
-	if op == "create" {
+	if op == "create" || op == "ensure" {
 		topics := make([]kafka.TopicSpecification, 0)
-		for _, table := range configs.TpchAllTables {
+		for _, name := range names {
 			topics = append(topics, kafka.TopicSpecification{
-				Topic:             string(table),
+				Topic:             name,
 				NumPartitions:     configs.KafkaPartition,
 				ReplicationFactor: 1,
 			})
 		}
-		results, err = client.CreateTopics(ctx, topics)
+		results, err = client.CreateTopics(ctx, topics, kafka.SetAdminOperationTimeout(configs.AdminOperationTimeout))
 	} else if op == "delete" {
-		topics := make([]string, 0)
-		for _, table := range configs.TpchAllTables {
-			topics = append(topics, string(table))
-		}
-		results, err = client.DeleteTopics(ctx, topics)
+		// wait until topics are deleted, or they could not be created again right after
+		results, err = client.DeleteTopics(ctx, names, kafka.SetAdminOperationTimeout(configs.AdminOperationTimeout))
 	} else {
 		return util.Errorf("Undefined kafka topic operation: %s", op)
 	}
@@ -65,36 +93,87 @@ func AdminTopics(op string) error {
 	}
 	for _, result := range results {
 		util.LogInfo("%s: %s", op, result.String())
+		code := result.Error.Code()
+		if code == kafka.ErrTopicAlreadyExists && op == "create" {
+			return util.Errorf("kafka topic %s is left by a previous run, run `bench clean` first", result.Topic)
+		}
+		// existing topics are kept by ensure, and topics never created need no deletion
+		if code == kafka.ErrNoError || code == kafka.ErrTopicAlreadyExists || code == kafka.ErrUnknownTopicOrPart {
+			continue
+		}
+		return util.Errorf("%s kafka topic %s error: %s", op, result.Topic, result.Error.String())
 	}
-
-	client.Close()
 	return nil
 }
 
+// ListTopics names of all topics in kafka, it fails if no broker answers within the timeout
+func ListTopics(timeout time.Duration) ([]string, error) {
+	client, err := kafka.NewAdminClient(&kafka.ConfigMap{
+		"bootstrap.servers": configs.KafkaAddr,
+	})
+	if err != nil {
+		return nil, util.Errorf("Create kafka admin client error: %s", err.Error())
+	}
+	defer client.Close()
+
+	metadata, err := client.GetMetadata(nil, true, int(timeout.Milliseconds()))
+	if err != nil {
+		return nil, util.Errorf("list kafka topics at %s error: %s", configs.KafkaAddr, err.Error())
+	}
+	topics := make([]string, 0, len(metadata.Topics))
+	for name := range metadata.Topics {
+		topics = append(topics, name)
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
 func (k *QueryKafkaExecutor) Prepare() error {
-	containOrder := false
-	containLineItem := false
-	for _, table := range k.config.Tables {
-		if table == configs.Orders {
-			containOrder = true
-		} else if table == configs.LineItem {
-			containLineItem = true
-		}
+	tablePartsMap, err := k.prepare()
+	if err != nil {
+		return err
+	}
+	// skewed keys are counted per run
+	data.ResetKeyCounters()
+	c := &data.TableGeneratorConfig{
+		ScaleFactor:   k.config.ScaleFactor,
+		TablePartsMap: tablePartsMap,
+		EventClock:    newEventClock(),
+		Unbounded:     k.unboundedTables(),
+	}
+	k.tableGen = data.NewTableGenerator(c)
+	return nil
+}
+
+// prepare checks distributions and plans producers, generators are not built yet
+func (k *QueryKafkaExecutor) prepare() (map[configs.TpchTable]int, error) {
+	err := data.CheckDistributions()
+	if err != nil {
+		return nil, err
 	}
-	if containOrder && containLineItem {
-		return k.prepareEventsSpecial()
+	err = data.GetDistributionManager().ValidateDrift(configs.DriftSchedule)
+	if err != nil {
+		return nil, err
 	}
 	return k.prepareEvents()
 }
 
-func (k *QueryKafkaExecutor) prepareEventsSpecial() error {
-	orderRateSum := int(float64(k.config.Rate) / 5)
-	lineitemRateSum := int(4 * float64(k.config.Rate) / 5)
-
-	orderProducers := int(math.Max(float64(orderRateSum/ProducerMaxRate), 1.0))
-	orderProducerRate := orderRateSum / orderProducers
-	lineitemProducers := int(math.Max(float64(lineitemRateSum/ProducerMaxRate), 1.0))
-	lineitemProducerRate := lineitemRateSum / lineitemProducers
+// prepareEvents streamed tables get their share of the total rate, and are split into parts
+// so that every producer sends at most ProducerMaxRate rows per second.
+// Other tables of the query are sent in batch by a single producer.
+// It returns the number of parts of every table
+func (k *QueryKafkaExecutor) prepareEvents() (map[configs.TpchTable]int, error) {
+	streamRates := make(map[configs.TpchTable]int)
+	ratioSum := 0.0
+	for _, stream := range k.config.Streams {
+		if !k.containTable(stream.Table) {
+			return nil, util.Errorf("streamed table %s is not involved in %s", stream.Table, k.config.QueryName)
+		}
+		ratioSum += stream.Ratio
+	}
+	for _, stream := range k.config.Streams {
+		streamRates[stream.Table] = int(float64(k.config.Rate) * stream.Ratio / ratioSum)
+	}
 
 	tablePartsMap := map[configs.TpchTable]int{
 		configs.LineItem: 0,
@@ -106,102 +185,150 @@ func (k *QueryKafkaExecutor) prepareEventsSpecial() error {
 		configs.Nation:   0,
 		configs.Region:   0,
 	}
+	for _, stream := range k.config.Streams {
+		tablePartsMap[stream.Table] = producerNums(streamRates[stream.Table])
+	}
+	// nation and region have a single generator, it is not split into parts
+	for _, table := range []configs.TpchTable{configs.Nation, configs.Region} {
+		if tablePartsMap[table] > 1 {
+			util.LogInfo("%s has a single generator, it is streamed by a single producer", table)
+			tablePartsMap[table] = 1
+		}
+	}
 
-	tablePartsMap[configs.LineItem] = lineitemProducers
-	k.producerCfs = append(k.producerCfs, &configs.KafkaProducerConfig{
-		Nums:  lineitemProducers,
-		Rate:  lineitemProducerRate,
-		Table: configs.LineItem,
-		Type:  configs.RealTime,
-	})
-	tablePartsMap[configs.Orders] = orderProducers
-	k.producerCfs = append(k.producerCfs, &configs.KafkaProducerConfig{
-		Nums:  orderProducers,
-		Rate:  orderProducerRate,
-		Table: configs.Orders,
-		Type:  configs.RealTime,
-	})
+	// the order and lineitems of the same part are coupled in causal mode
+	_, streamOrders := streamRates[configs.Orders]
+	_, streamLineItem := streamRates[configs.LineItem]
+	if configs.CausalOrders {
+		if streamOrders && streamLineItem {
+			tablePartsMap[configs.Orders] = tablePartsMap[configs.LineItem]
+			k.causalGates = make([]*CausalGate, tablePartsMap[configs.LineItem])
+			for i := range k.causalGates {
+				k.causalGates[i] = NewCausalGate(configs.CausalMaxSkew)
+			}
+		} else {
+			util.LogInfo("causal mode is ignored, orders and lineitem are not both streamed")
+		}
+	}
 
+	for _, stream := range k.config.Streams {
+		parts := tablePartsMap[stream.Table]
+		k.producerCfs = append(k.producerCfs, &configs.KafkaProducerConfig{
+			Nums:  parts,
+			Rate:  int(math.Max(float64(streamRates[stream.Table]/parts), 1.0)),
+			Table: stream.Table,
+			Type:  configs.RealTime,
+		})
+	}
 	for _, table := range k.config.Tables {
-		if table != configs.LineItem && table != configs.Orders {
-			tablePartsMap[table] = 1
-			k.producerCfs = append(k.producerCfs, &configs.KafkaProducerConfig{
-				Nums:  1,
-				Rate:  -1,
-				Table: table,
-				Type:  configs.Batch,
-			})
+		if _, ok := streamRates[table]; ok {
+			continue
 		}
+		tablePartsMap[table] = 1
+		k.producerCfs = append(k.producerCfs, &configs.KafkaProducerConfig{
+			Nums:  1,
+			Rate:  -1,
+			Table: table,
+			Type:  configs.Batch,
+		})
 	}
+	return tablePartsMap, nil
+}
 
-	c := &data.TableGeneratorConfig{
-		ScaleFactor:   k.config.ScaleFactor,
-		TablePartsMap: tablePartsMap,
+func (k *QueryKafkaExecutor) containTable(table configs.TpchTable) bool {
+	for _, t := range k.config.Tables {
+		if t == table {
+			return true
+		}
 	}
-	k.tableGen = data.NewTableGenerator(c)
-	return nil
+	return false
 }
 
-func (k *QueryKafkaExecutor) prepareEvents() error {
-	sendRate := k.config.Rate
-	producerNums := sendRate / ProducerMaxRate
-	if sendRate%ProducerMaxRate != 0 {
-		producerNums += 1
+// producerNums producers needed to send `rate` rows per second
+func producerNums(rate int) int {
+	nums := rate / ProducerMaxRate
+	if rate%ProducerMaxRate != 0 {
+		nums += 1
 	}
-	producerRate := sendRate / producerNums
+	return int(math.Max(float64(nums), 1.0))
+}
 
-	tablePartsMap := map[configs.TpchTable]int{
-		configs.LineItem: 0,
-		configs.Orders:   0,
-		configs.Customer: 0,
-		configs.Part:     0,
-		configs.Supplier: 0,
-		configs.PartSupp: 0,
-		configs.Nation:   0,
-		configs.Region:   0,
+// unboundedTables realtime tables that keep generating beyond the scale factor
+func (k *QueryKafkaExecutor) unboundedTables() []configs.TpchTable {
+	tables := make([]configs.TpchTable, 0)
+	if !configs.UnboundedStream() {
+		return tables
 	}
-	for _, table := range k.config.Tables {
-		if table == k.config.MainTable {
-			tablePartsMap[k.config.MainTable] = producerNums
-			k.producerCfs = append(k.producerCfs, &configs.KafkaProducerConfig{
-				Nums:  producerNums,
-				Rate:  producerRate,
-				Table: k.config.MainTable,
-				Type:  configs.RealTime,
-			})
+	for _, cf := range k.producerCfs {
+		if cf.Type != configs.RealTime {
+			continue
+		}
+		if cf.Table == configs.Orders || cf.Table == configs.LineItem {
+			tables = append(tables, cf.Table)
 		} else {
-			tablePartsMap[table] = 1
-			k.producerCfs = append(k.producerCfs, &configs.KafkaProducerConfig{
-				Nums:  1,
-				Rate:  -1,
-				Table: table,
-				Type:  configs.Batch,
-			})
+			util.LogInfo("%s could not be generated beyond the scale factor, it stops when exhausted", cf.Table)
 		}
 	}
+	return tables
+}
 
-	c := &data.TableGeneratorConfig{
-		ScaleFactor:   k.config.ScaleFactor,
-		TablePartsMap: tablePartsMap,
+func newEventClock() *data.EventClock {
+	if !configs.EventTimeEnabled {
+		return nil
 	}
-	k.tableGen = data.NewTableGenerator(c)
+	return data.NewEventClock(configs.EventTimeSpeedup)
+}
 
-	return nil
+// ObserveMVRows shows the latest row count of the sampled mv in progress lines, it's safe to call while producing
+func (k *QueryKafkaExecutor) ObserveMVRows(rows int64) {
+	atomic.StoreInt64(&k.mvRows, rows)
+}
+
+// TrackInputTimeline realtime producers record flush times of their batches to `timeline`
+func (k *QueryKafkaExecutor) TrackInputTimeline(timeline *InputTimeline) {
+	k.timeline = timeline
 }
 
-func (k *QueryKafkaExecutor) SendKafkaBatch() {
+func (k *QueryKafkaExecutor) SendKafkaBatch(ctx context.Context) error {
 	util.LogInfo("------Insert small tables in advance------")
-	k.send(k.getProducers(configs.Batch))
+	producers, err := k.getProducers(configs.Batch)
+	if err != nil {
+		return err
+	}
+	err = k.send(ctx, producers)
+	for _, producer := range producers {
+		k.batchRows[configs.TpchTable(producer.topic)] += producer.Rows()
+	}
+	return err
 }
 
-func (k *QueryKafkaExecutor) SendKafkaRealTime() {
+// SendKafkaRealTime returns the number of rows produced in realtime
+func (k *QueryKafkaExecutor) SendKafkaRealTime(ctx context.Context) (int64, error) {
 	util.LogInfo("------Start benchmark streaming------")
 	var timer = time.Now()
-	k.send(k.getProducers(configs.RealTime))
+	producers, err := k.getProducers(configs.RealTime)
+	if err != nil {
+		return 0, err
+	}
+	done := make(chan struct{})
+	go runDriftSchedule(configs.DriftSchedule, done)
+	err = k.send(ctx, producers)
+	close(done)
+	k.reportCausal()
 	util.LogInfo("------Produce data in real time totally takes %f seconds------", time.Now().Sub(timer).Seconds())
+	rows := int64(0)
+	for _, producer := range producers {
+		rows += producer.Sent()
+	}
+	return rows, err
+}
+
+// BatchRows rows of each table generated by SendKafkaBatch, they are all visible in the system once ingested
+func (k *QueryKafkaExecutor) BatchRows() map[configs.TpchTable]int64 {
+	return k.batchRows
 }
 
-func (k *QueryKafkaExecutor) getProducers(sendType string) []*KafkaProducer {
+func (k *QueryKafkaExecutor) getProducers(sendType string) ([]*KafkaProducer, error) {
 	producers := make([]*KafkaProducer, 0)
 	idx := 0
 	for _, cf := range k.producerCfs {
@@ -211,23 +338,42 @@ func (k *QueryKafkaExecutor) getProducers(sendType string) []*KafkaProducer {
 		for i := 0; i < cf.Nums; i++ {
 			producer, err := NewKafkaProducer(idx, cf, k.tableGen.GetSingleTableGenerator(cf.Table, i))
 			if err != nil {
-				util.LogErr("connect to kafka error: %s", err.Error())
+				for _, p := range producers {
+					p.producer.Close()
+				}
+				return nil, util.Errorf("connect to kafka error: %s", err.Error())
+			}
+			if cf.Type == configs.RealTime {
+				producer.timeline = k.timeline
+			}
+			if k.causalGates != nil && (cf.Table == configs.Orders || cf.Table == configs.LineItem) {
+				producer.causal = k.causalGates[i]
 			}
 			producers = append(producers, producer)
 			idx++
 		}
 	}
-	return producers
+	return producers, nil
 }
 
-func (k *QueryKafkaExecutor) send(producers []*KafkaProducer) {
+// send returns once all producers finish, or stop and flush after ctx is canceled.
+// It returns an error if any row is not delivered to kafka
+func (k *QueryKafkaExecutor) send(ctx context.Context, producers []*KafkaProducer) error {
 	if len(producers) == 0 {
-		return
+		return nil
 	}
 	util.LogInfo("Producer number[%d]", len(producers))
+	// the key skew report covers this send only
+	data.ClearKeyCounts()
 
 	for _, producer := range producers {
-		go producer.WriteRowsToKafka()
+		go producer.WriteRowsToKafka(ctx)
+	}
+
+	if configs.ProgressInterval > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go newProgressReporter(producers, &k.mvRows).run(done)
 	}
 
 	var waitGroup sync.WaitGroup
@@ -237,7 +383,7 @@ func (k *QueryKafkaExecutor) send(producers []*KafkaProducer) {
 			for {
 				_, ok := <-events
 				if !ok {
-					util.LogInfo("producer[%d]---finish---Send totally [%d]", id, producers[id].Size())
+					producers[id].log.Info("finished, %d rows sent", producers[id].Sent())
 					break
 				}
 			}
@@ -245,4 +391,48 @@ func (k *QueryKafkaExecutor) send(producers []*KafkaProducer) {
 		}(i, producers[i].Events())
 	}
 	waitGroup.Wait()
+	reportDisorder(producers)
+	data.ReportKeySkew(configs.SkewTopK)
+
+	failed := int64(0)
+	for _, producer := range producers {
+		failed += producer.Failed()
+	}
+	if failed > 0 {
+		return util.Errorf("%d rows are not delivered to kafka", failed)
+	}
+	return nil
+}
+
+func (k *QueryKafkaExecutor) reportCausal() {
+	if k.causalGates == nil {
+		return
+	}
+	var total CausalStats
+	for _, gate := range k.causalGates {
+		total.Merge(gate.Stats())
+	}
+	util.LogInfo("causal: max skew[%d orders] lineitem waits for orders[%v] orders wait for lineitem[%v]",
+		total.MaxSkew, total.LineItemWait, total.OrdersWait)
+}
+
+func reportDisorder(producers []*KafkaProducer) {
+	var total DisorderStats
+	for _, producer := range producers {
+		if stats := producer.DisorderStats(); stats != nil {
+			total.Merge(stats)
+		}
+	}
+	if total.Rows == 0 {
+		return
+	}
+	avgDelay := time.Duration(0)
+	if total.Delayed+total.Late > 0 {
+		avgDelay = total.TotalDelay / time.Duration(total.Delayed+total.Late)
+	}
+	util.LogInfo("disorder: rows[%d] delayed[%d, %.4f] late[%d, %.4f] shuffled[%d] delay avg[%v] max[%v]",
+		total.Rows,
+		total.Delayed, float64(total.Delayed)/float64(total.Rows),
+		total.Late, float64(total.Late)/float64(total.Rows),
+		total.Shuffled, avgDelay, total.MaxDelay)
 }
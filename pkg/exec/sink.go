@@ -0,0 +1,165 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SinkName sink from a mv, its output topic has the same name
+func SinkName(mv string) string {
+	return mv + "_sink"
+}
+
+func SinkStatement(mv string) string {
+	return fmt.Sprintf("create sink %s from %s with ("+
+		"'connector'='kafka', 'kafka.brokers'='%s', 'kafka.topic'='%s', 'format'='debezium')",
+		SinkName(mv), mv, configs.KafkaAddrForFrontend, SinkName(mv))
+}
+
+// InputTimeline flush times of realtime batches, shared by all realtime producers
+type InputTimeline struct {
+	mutex   sync.Mutex
+	flushes []time.Time
+}
+
+func NewInputTimeline() *InputTimeline {
+	return &InputTimeline{
+		flushes: make([]time.Time, 0),
+	}
+}
+
+func (t *InputTimeline) Add(flushed time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.flushes = append(t.flushes, flushed)
+}
+
+// Flushes sorted flush times
+func (t *InputTimeline) Flushes() []time.Time {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	flushes := append([]time.Time(nil), t.flushes...)
+	sort.Slice(flushes, func(i, j int) bool {
+		return flushes[i].Before(flushes[j])
+	})
+	return flushes
+}
+
+type SinkStats struct {
+	Changes     int64           // messages in the output topic
+	Latencies   []time.Duration // time from an input batch flushed to the output change it causes being received
+	Unmatched   int64           // input batches that cause no output change
+	FirstOutput time.Time
+	LastOutput  time.Time
+}
+
+// SinkOutput an output change of the sink
+type SinkOutput struct {
+	Produced time.Time // timestamp of the kafka message, set when the sink writes the change
+	Received time.Time
+}
+
+// CorrelateLatencies credits every output change to the newest input batch flushed before the sink produced it,
+// that is the latest input the change could include. A batch is credited by its first output change only,
+// batches superseded by a newer batch before any output change are unmatched. Both are sorted
+func CorrelateLatencies(inputs []time.Time, outputs []SinkOutput) ([]time.Duration, int64) {
+	latencies := make([]time.Duration, 0, len(inputs))
+	credited := -1
+	for _, output := range outputs {
+		i := sort.Search(len(inputs), func(i int) bool {
+			return inputs[i].After(output.Produced)
+		}) - 1
+		if i <= credited {
+			// produced before any input, or caused by an input already credited
+			continue
+		}
+		latencies = append(latencies, output.Received.Sub(inputs[i]))
+		credited = i
+	}
+	return latencies, int64(len(inputs) - len(latencies))
+}
+
+// SinkConsumer reads the changelog of a mv from the output topic of its sink
+type SinkConsumer struct {
+	consumer *kafka.Consumer
+	outputs  []SinkOutput
+}
+
+func NewSinkConsumer(topic string) (*SinkConsumer, error) {
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers": configs.KafkaAddr,
+		"group.id":          fmt.Sprintf("tpch-bench-%s", topic),
+		"auto.offset.reset": "earliest",
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = consumer.Subscribe(topic, nil)
+	if err != nil {
+		_ = consumer.Close()
+		return nil, err
+	}
+	return &SinkConsumer{
+		consumer,
+		make([]SinkOutput, 0),
+	}, nil
+}
+
+// Run consumes output changes until production finishes, i.e. done is closed,
+// and no output change is received for configs.SinkIdleTimeout
+func (s *SinkConsumer) Run(ctx context.Context, done <-chan struct{}) {
+	defer s.consumer.Close()
+	finished := false
+	idleSince := time.Now()
+	for ctx.Err() == nil {
+		select {
+		case <-done:
+			finished = true
+			done = nil
+			idleSince = time.Now()
+		default:
+		}
+		if finished && time.Now().Sub(idleSince) > configs.SinkIdleTimeout {
+			return
+		}
+		msg, err := s.consumer.ReadMessage(100 * time.Millisecond)
+		if err != nil {
+			if kafkaErr, ok := err.(kafka.Error); !ok || kafkaErr.Code() != kafka.ErrTimedOut {
+				util.LogErr("consume sink error: %s", err.Error())
+			}
+			continue
+		}
+		if msg != nil {
+			received := time.Now()
+			produced := msg.Timestamp
+			if msg.TimestampType == kafka.TimestampNotAvailable {
+				produced = received
+			}
+			s.outputs = append(s.outputs, SinkOutput{produced, received})
+			idleSince = received
+		}
+	}
+}
+
+// Stats correlates received output changes with input batches, called after Run returns
+func (s *SinkConsumer) Stats(timeline *InputTimeline) *SinkStats {
+	stats := &SinkStats{
+		Changes: int64(len(s.outputs)),
+	}
+	if len(s.outputs) > 0 {
+		stats.FirstOutput = s.outputs[0].Received
+		stats.LastOutput = s.outputs[len(s.outputs)-1].Received
+	}
+	outputs := append([]SinkOutput(nil), s.outputs...)
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].Produced.Before(outputs[j].Produced)
+	})
+	stats.Latencies, stats.Unmatched = CorrelateLatencies(timeline.Flushes(), outputs)
+	return stats
+}
@@ -2,6 +2,7 @@ package exec
 
 import (
 	"bufio"
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/singularity-data/tpch-bench/pkg/configs"
@@ -29,6 +30,15 @@ type SQLStatement struct {
 	expectedResult string // ground truth
 }
 
+// SQL content of the statement
+func (s *SQLStatement) SQL() string {
+	return s.sql
+}
+
+func (s *SQLStatement) IsQuery() bool {
+	return s.sqlType == SqlQuery
+}
+
 type SQLExecutor struct {
 	db *sql.DB
 }
@@ -39,78 +49,118 @@ func NewSQLExecutor(db *sql.DB) *SQLExecutor {
 	}
 }
 
-func (s *SQLExecutor) ExecuteSQLStatement(sql string) error {
+func (s *SQLExecutor) ExecuteSQLStatement(ctx context.Context, sql string) error {
 	util.LogInfo("Exec SQL statement from internal")
 	sqlStmt := &SQLStatement{
 		sqlType: SqlStatement,
 		meta:    fmt.Sprintf("internal sql"),
 		sql:     sql,
 	}
-	if err := s.executeStatement(sqlStmt); err != nil {
+	if err := s.executeStatement(ctx, sqlStmt); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *SQLExecutor) ExecuteSQLQuery(sql string) (error, string) {
+func (s *SQLExecutor) ExecuteSQLQuery(ctx context.Context, sql string) (error, string) {
 	util.LogInfo("Exec SQL query from internal")
 	sqlStmt := &SQLStatement{
 		sqlType: SqlQuery,
 		meta:    fmt.Sprintf("internal sql"),
 		sql:     sql,
 	}
-	if err := s.executeQuery(sqlStmt); err != nil {
+	if err := s.executeQuery(ctx, sqlStmt); err != nil {
 		return err, ""
 	}
 	return nil, sqlStmt.result
 }
 
-func (s *SQLExecutor) ExecuteSQLFile(scanner *bufio.Scanner, fname string, typ configs.SQLStmtType) error {
-	util.LogInfo("Exec SQL file: %s", fname)
-	parser := NewSQLFileParser(scanner)
-	for parser.NextLine() {
-		line := parser.Text()
-		fields := strings.Fields(line)
-		if len(fields) == 0 {
-			continue
-		}
+// QueryCount number of rows visible in a table or mv, it logs nothing so that it could be polled
+func (s *SQLExecutor) QueryCount(ctx context.Context, relation string) (int64, error) {
+	var count int64
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf("select count(*) from %s", relation)).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
 
-		cmd := fields[0]
-		if strings.HasPrefix(cmd, "#") {
-			// skip comment lines.
-			continue
-		}
-		if len(fields) != 1 {
-			return util.Errorf("SQLFile fmt error, expect [cmd], found [%s]", cmd)
+// QueryInt value of a query returning a single integer, it logs nothing so that it could be polled
+func (s *SQLExecutor) QueryInt(ctx context.Context, query string) (int64, error) {
+	var value int64
+	err := s.db.QueryRowContext(ctx, query).Scan(&value)
+	if err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// QueryResult result of a query in the same format as ExecuteSQLQuery, it logs nothing so that it could be polled
+func (s *SQLExecutor) QueryResult(ctx context.Context, sql string) (string, error) {
+	sqlStmt := &SQLStatement{
+		sqlType: SqlQuery,
+		meta:    fmt.Sprintf("internal sql"),
+		sql:     sql,
+	}
+	if err := s.runQuery(ctx, sqlStmt); err != nil {
+		return "", err
+	}
+	return sqlStmt.result, nil
+}
+
+// QueryNames first column of all rows of a query, ex: names listed by `show sources`, it logs nothing
+func (s *SQLExecutor) QueryNames(ctx context.Context, query string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		return nil, util.Errorf("no column is returned by %s", query)
+	}
+	names := make([]string, 0)
+	for rows.Next() {
+		cells := make([]interface{}, len(cols))
+		for i := range cells {
+			cells[i] = new(sql.NullString)
 		}
-		sqlStmt := &SQLStatement{
-			meta: fmt.Sprintf("File name: %s, Line number: %d", fname, parser.LineNumber()),
+		if err := rows.Scan(cells...); err != nil {
+			return nil, err
 		}
-		switch cmd {
-		case "statement":
-			sqlStmt.sqlType = SqlStatement
-			err := parser.parseStatement(sqlStmt)
-			if err != nil {
-				return err
-			}
+		names = append(names, cells[0].(*sql.NullString).String)
+	}
+	return names, rows.Err()
+}
+
+// SameResult whether two query results are the same regardless of whitespace
+func SameResult(result string, expected string) bool {
+	return strings.Join(strings.Fields(result), "") == strings.Join(strings.Fields(expected), "")
+}
+
+func (s *SQLExecutor) ExecuteSQLFile(ctx context.Context, scanner *bufio.Scanner, fname string, typ configs.SQLStmtType) error {
+	util.LogInfo("Exec SQL file: %s", fname)
+	stmts, err := ParseSQLFile(scanner, fname)
+	if err != nil {
+		return err
+	}
+	for _, sqlStmt := range stmts {
+		switch sqlStmt.sqlType {
+		case SqlStatement:
 			if typ == configs.SQLCreateSource {
 				s.warpKafkaStatement(sqlStmt)
 			}
-			if err := s.executeStatement(sqlStmt); err != nil {
+			if err := s.executeStatement(ctx, sqlStmt); err != nil {
 				return err
 			}
-		case "query":
-			sqlStmt.sqlType = SqlQuery
-			err := parser.parseStatement(sqlStmt)
-			if err != nil {
+		case SqlQuery:
+			if err := s.executeQuery(ctx, sqlStmt); err != nil {
 				return err
 			}
-			err = s.executeQuery(sqlStmt)
-			if err != nil {
-				return err
-			}
-		default:
-			return util.Errorf("unknown command: %s", cmd)
 		}
 		util.LogInfo("ok... rows affected: %d", sqlStmt.rowsNum)
 	}
@@ -153,14 +203,19 @@ func (s *SQLExecutor) checkQueryResult(stmt *SQLStatement) bool {
 }
 
 func (s *SQLExecutor) warpKafkaStatement(stmt *SQLStatement) {
+	stmt.sql = wrapKafkaAddr(stmt.sql)
+}
+
+// wrapKafkaAddr source tables read from the kafka address reachable from the frontend
+func wrapKafkaAddr(sql string) string {
 	reg := regexp.MustCompile(`localhost:9092`)
-	stmt.sql = reg.ReplaceAllString(stmt.sql, configs.KafkaAddrForFrontend)
+	return reg.ReplaceAllString(sql, configs.KafkaAddrForFrontend)
 }
 
-func (s *SQLExecutor) executeStatement(stmt *SQLStatement) error {
+func (s *SQLExecutor) executeStatement(ctx context.Context, stmt *SQLStatement) error {
 	util.LogInfo("Exec SQL statement")
 	start := time.Now()
-	res, err := s.db.Exec(stmt.sql)
+	res, err := s.db.ExecContext(ctx, stmt.sql)
 	duration := time.Now().Sub(start)
 	util.LogInfo("duration: %f seconds", duration.Seconds())
 	stmt.isExecuted = true
@@ -175,9 +230,13 @@ func (s *SQLExecutor) executeStatement(stmt *SQLStatement) error {
 	return nil
 }
 
-func (s *SQLExecutor) executeQuery(query *SQLStatement) error {
+func (s *SQLExecutor) executeQuery(ctx context.Context, query *SQLStatement) error {
 	util.LogInfo("Exec SQL Query")
-	rows, err := s.db.Query(query.sql)
+	return s.runQuery(ctx, query)
+}
+
+func (s *SQLExecutor) runQuery(ctx context.Context, query *SQLStatement) error {
+	rows, err := s.db.QueryContext(ctx, query.sql)
 	if err != nil {
 		return err
 	}
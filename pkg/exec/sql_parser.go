@@ -2,6 +2,9 @@ package exec
 
 import (
 	"bufio"
+	"fmt"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/util"
 	"strings"
 )
 
@@ -17,6 +20,60 @@ func NewSQLFileParser(s *bufio.Scanner) *SQLFileParser {
 	}
 }
 
+// ParseSQLFile parses all statements and queries of a sql file without executing them
+func ParseSQLFile(scanner *bufio.Scanner, fname string) ([]*SQLStatement, error) {
+	stmts := make([]*SQLStatement, 0)
+	parser := NewSQLFileParser(scanner)
+	for parser.NextLine() {
+		line := parser.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		cmd := fields[0]
+		if strings.HasPrefix(cmd, "#") {
+			// skip comment lines.
+			continue
+		}
+		if len(fields) != 1 {
+			return nil, util.Errorf("SQLFile fmt error, expect [cmd], found [%s]", cmd)
+		}
+		sqlStmt := &SQLStatement{
+			meta: fmt.Sprintf("File name: %s, Line number: %d", fname, parser.LineNumber()),
+		}
+		switch cmd {
+		case "statement":
+			sqlStmt.sqlType = SqlStatement
+		case "query":
+			sqlStmt.sqlType = SqlQuery
+		default:
+			return nil, util.Errorf("unknown command: %s", cmd)
+		}
+		err := parser.parseStatement(sqlStmt)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, sqlStmt)
+	}
+	return stmts, nil
+}
+
+// RenderSQLFile statements and queries of a sql file as they would be executed, kafka addresses of
+// source tables are substituted
+func RenderSQLFile(scanner *bufio.Scanner, fname string, typ configs.SQLStmtType) ([]*SQLStatement, error) {
+	stmts, err := ParseSQLFile(scanner, fname)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range stmts {
+		if stmt.sqlType == SqlStatement && typ == configs.SQLCreateSource {
+			stmt.sql = wrapKafkaAddr(stmt.sql)
+		}
+	}
+	return stmts, nil
+}
+
 func (s *SQLFileParser) NextLine() bool {
 	ok := s.scanner.Scan()
 	if ok {
@@ -1,12 +1,277 @@
 package metric
 
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// names of metrics
+const (
+	BackfillSeconds  string = "backfill_seconds"   // time for the system to ingest tables sent in batch
+	BatchSendSeconds string = "batch_send_seconds" // time to send all tables to kafka in snapshot backfill
+	MVCreateSeconds  string = "mv_create_seconds"  // time of the create mv statement in snapshot backfill
+	CatchUpSeconds   string = "catchup_seconds"    // time from creating the mv to its final result in snapshot backfill
+	ProduceSeconds   string = "produce_seconds"    // time of producing rows in realtime
+	ProducedRows     string = "produced_rows"      // rows produced in realtime
+	ProduceRate      string = "produce_rows_per_second"
+	ConvergeSeconds  string = "convergence_seconds" // time from the last produced row to the final stable result
+	ProbesSent       string = "probes_sent"
+	ProbesLost       string = "probes_lost" // probes never visible in the probe mv
+	SinkChanges      string = "sink_changes"
+	SinkChangeRate   string = "sink_change_rate"           // output changes per second
+	SinkUnmatched    string = "sink_unmatched"             // input batches that cause no output change
+	SinkFinalLatency string = "sink_final_latency_seconds" // time from the last input batch to the last output change
+	ResultMatched    string = "result_matched"             // 1 if the stable result matches the expectation of the query, 0 if not
+)
+
+// prefixes of latency percentiles, ex: freshness_p99_seconds
+const (
+	Freshness   string = "freshness"    // time from a probe published to visible
+	SinkLatency string = "sink_latency" // time from an input batch flushed to the output change of the sink it causes
+)
+
 type BasicMetric struct {
 	tps float64
 }
 
+// MetricsManager collects metrics of one run, it's safe for concurrent use
 type MetricsManager struct {
+	mutex       sync.Mutex
+	start       time.Time
+	interrupted bool
+	metrics     map[string]float64
+	samples     []Sample
+}
+
+// Sample row count and digest of the result of the mv at a time, the result itself may be too large to keep
+type Sample struct {
+	Time   time.Time `json:"time"`
+	Rows   int64     `json:"rows"`
+	Digest string    `json:"digest"`
 }
 
 func NewMetricsManager() *MetricsManager {
-	return &MetricsManager{}
+	return &MetricsManager{
+		start:   time.Now(),
+		metrics: make(map[string]float64),
+	}
+}
+
+func (m *MetricsManager) Record(name string, value float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.metrics[name] = value
+}
+
+func (m *MetricsManager) AddSample(at time.Time, rows int64, digest string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.samples = append(m.samples, Sample{at, rows, digest})
+}
+
+// MarkInterrupted the run is stopped by a signal, metrics in the report are partial
+func (m *MetricsManager) MarkInterrupted() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.interrupted = true
+}
+
+func (m *MetricsManager) Get(name string) (float64, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	value, ok := m.metrics[name]
+	return value, ok
+}
+
+// Names of recorded metrics in order
+func (m *MetricsManager) Names() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	names := make([]string, 0, len(m.metrics))
+	for name := range m.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RecordLatencies records p50, p90, p99 and max of latencies as <prefix>_p50_seconds, etc.
+func (m *MetricsManager) RecordLatencies(prefix string, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	seconds := make([]float64, 0, len(latencies))
+	for _, latency := range latencies {
+		seconds = append(seconds, latency.Seconds())
+	}
+	sort.Float64s(seconds)
+	for _, p := range []float64{50, 90, 99} {
+		m.Record(fmt.Sprintf("%s_p%d_seconds", prefix, int(p)), Percentile(seconds, p))
+	}
+	m.Record(fmt.Sprintf("%s_max_seconds", prefix), seconds[len(seconds)-1])
+}
+
+// Percentile nearest-rank percentile p in (0, 100] of values sorted ascending, 0 if no value
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	} else if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// Report of a run, written as json
+type Report struct {
+	StartTime   time.Time                    `json:"start_time"`
+	EndTime     time.Time                    `json:"end_time"`
+	Interrupted bool                         `json:"interrupted"`
+	Metrics     map[string]float64           `json:"metrics"`
+	Samples     []Sample                     `json:"samples,omitempty"`
+	Scenario    map[string]map[string]string `json:"scenario,omitempty"` // resolved flags of the run, without secrets
+	Status      string                       `json:"status,omitempty"`   // set by a single run, a suite keeps it in QueryReport
+	Error       string                       `json:"error,omitempty"`
+}
+
+func (m *MetricsManager) Report() *Report {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	metrics := make(map[string]float64, len(m.metrics))
+	for name, value := range m.metrics {
+		metrics[name] = value
+	}
+	return &Report{
+		StartTime:   m.start,
+		EndTime:     time.Now(),
+		Interrupted: m.interrupted,
+		Metrics:     metrics,
+		Samples:     append([]Sample(nil), m.samples...),
+	}
+}
+
+// SetStatus status of a single run from the error stopping it, in the same way as a query of a suite
+func (r *Report) SetStatus(err error) {
+	r.Status = QueryStatus(err, r.Interrupted, r)
+	if err != nil && !r.Interrupted {
+		r.Error = err.Error()
+	}
+}
+
+func (r *Report) Write(path string) error {
+	return writeJSON(path, r)
+}
+
+// statuses of a query in a suite
+const (
+	StatusPassed      string = "passed"      // the query finishes, and its result matches the expectation if there is any
+	StatusMismatched  string = "mismatched"  // the query finishes, but its result does not match the expectation
+	StatusFailed      string = "failed"      // an error stops the query
+	StatusInterrupted string = "interrupted" // a signal stops the query
+	StatusSkipped     string = "skipped"     // the suite is interrupted before the query starts
+)
+
+// QueryReport status and metrics of a query in a suite, no report if the query is skipped
+type QueryReport struct {
+	Query  string  `json:"query"`
+	Status string  `json:"status"`
+	Error  string  `json:"error,omitempty"`
+	Report *Report `json:"report,omitempty"`
+}
+
+// SuiteReport reports of queries in a suite, in the order they are run
+type SuiteReport struct {
+	StartTime   time.Time                    `json:"start_time"`
+	EndTime     time.Time                    `json:"end_time"`
+	Interrupted bool                         `json:"interrupted"`
+	Queries     []*QueryReport               `json:"queries"`
+	Scenario    map[string]map[string]string `json:"scenario,omitempty"` // resolved flags of the suite, without secrets
+}
+
+func NewSuiteReport() *SuiteReport {
+	return &SuiteReport{
+		StartTime: time.Now(),
+		Queries:   make([]*QueryReport, 0),
+	}
+}
+
+// QueryStatus status of a finished query from the error stopping it and its metrics
+func QueryStatus(err error, interrupted bool, report *Report) string {
+	if interrupted {
+		return StatusInterrupted
+	}
+	if matched, ok := report.Metrics[ResultMatched]; ok && matched == 0 {
+		return StatusMismatched
+	}
+	if err != nil {
+		return StatusFailed
+	}
+	return StatusPassed
+}
+
+// Passed whether every query of the suite passes
+func (s *SuiteReport) Passed() bool {
+	for _, query := range s.Queries {
+		if query.Status != StatusPassed {
+			return false
+		}
+	}
+	return !s.Interrupted
+}
+
+// ReadReport reads a report written by a suite, or by a single run as a suite of one query
+func ReadReport(path string) (*SuiteReport, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	suite := &SuiteReport{}
+	if err := json.Unmarshal(content, suite); err != nil {
+		return nil, err
+	}
+	if suite.Queries != nil {
+		return suite, nil
+	}
+	report := &Report{}
+	if err := json.Unmarshal(content, report); err != nil {
+		return nil, err
+	}
+	status := report.Status
+	if status == "" {
+		// written before runs recorded their status, the error is unknown
+		status = QueryStatus(nil, report.Interrupted, report)
+	}
+	return &SuiteReport{
+		StartTime:   report.StartTime,
+		EndTime:     report.EndTime,
+		Interrupted: report.Interrupted,
+		Queries: []*QueryReport{{
+			Query:  report.Scenario["workload"]["query"],
+			Status: status,
+			Error:  report.Error,
+			Report: report,
+		}},
+		Scenario: report.Scenario,
+	}, nil
+}
+
+func (s *SuiteReport) WriteReport(path string) error {
+	s.EndTime = time.Now()
+	return writeJSON(path, s)
+}
+
+func writeJSON(path string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
 }
@@ -1,22 +1,243 @@
 package util
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
-const LogLevel int32 = 0
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if levelName == strings.ToLower(name) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, Errorf("unknown log level: %s, it should be one of %s", name, strings.Join(levelNames, ", "))
+}
+
+// log formats
+const (
+	LogText string = "text" // [info] message key=value
+	LogJSON string = "json" // {"time": ..., "level": "info", "msg": "message", "key": "value"}
+)
+
+// output of all loggers, lines are never interleaved
+var output = struct {
+	mutex  sync.Mutex
+	level  Level
+	format string
+	writer io.Writer
+	fields map[string]interface{} // process-wide fields, ex: query and phase
+}{
+	level:  LevelInfo,
+	format: LogText,
+	writer: os.Stdout,
+	fields: make(map[string]interface{}),
+}
+
+func SetLogLevel(level Level) {
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+	output.level = level
+}
+
+func SetLogFormat(format string) error {
+	if format != LogText && format != LogJSON {
+		return Errorf("unknown log format: %s, it should be %s or %s", format, LogText, LogJSON)
+	}
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+	output.format = format
+	return nil
+}
+
+// SetLogFile logs are appended to the file besides stdout, the returned function closes it
+func SetLogFile(path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+	output.writer = io.MultiWriter(os.Stdout, file)
+	return func() {
+		output.mutex.Lock()
+		defer output.mutex.Unlock()
+		output.writer = os.Stdout
+		_ = file.Close()
+	}, nil
+}
+
+// SetField attaches a field to all following lines, an empty value removes it
+func SetField(key string, value interface{}) {
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+	if value == "" {
+		delete(output.fields, key)
+		return
+	}
+	output.fields[key] = value
+}
+
+// Logger attaches its fields to every line, ex: producer id and table
+type Logger struct {
+	keys   []string
+	values []interface{}
+}
+
+// With returns a logger with fields in key-value pairs
+func With(kvs ...interface{}) *Logger {
+	return (&Logger{}).With(kvs...)
+}
+
+func (l *Logger) With(kvs ...interface{}) *Logger {
+	child := &Logger{
+		append([]string(nil), l.keys...),
+		append([]interface{}(nil), l.values...),
+	}
+	for i := 0; i+1 < len(kvs); i += 2 {
+		child.keys = append(child.keys, fmt.Sprint(kvs[i]))
+		child.values = append(child.values, kvs[i+1])
+	}
+	return child
+}
+
+func (l *Logger) Debug(str string, args ...interface{}) {
+	l.log(LevelDebug, str, args...)
+}
+
+func (l *Logger) Info(str string, args ...interface{}) {
+	l.log(LevelInfo, str, args...)
+}
+
+func (l *Logger) Warn(str string, args ...interface{}) {
+	l.log(LevelWarn, str, args...)
+}
+
+func (l *Logger) Error(str string, args ...interface{}) {
+	l.log(LevelError, str, args...)
+}
+
+func (l *Logger) log(level Level, str string, args ...interface{}) {
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+	if level < output.level {
+		return
+	}
+	msg := fmt.Sprintf(str, args...)
+
+	// fields of the logger first, then process-wide ones in order
+	keys := append([]string(nil), l.keys...)
+	values := append([]interface{}(nil), l.values...)
+	globalKeys := make([]string, 0, len(output.fields))
+	for key := range output.fields {
+		globalKeys = append(globalKeys, key)
+	}
+	sort.Strings(globalKeys)
+	for _, key := range globalKeys {
+		keys = append(keys, key)
+		values = append(values, output.fields[key])
+	}
+
+	var line string
+	if output.format == LogJSON {
+		line = jsonLine(time.Now(), level, msg, keys, values)
+	} else {
+		var builder strings.Builder
+		builder.WriteString(fmt.Sprintf("[%s] %s", level, msg))
+		for i, key := range keys {
+			builder.WriteString(fmt.Sprintf(" %s=%v", key, values[i]))
+		}
+		line = builder.String()
+	}
+	_, _ = fmt.Fprintln(output.writer, line)
+}
+
+func jsonLine(now time.Time, level Level, msg string, keys []string, values []interface{}) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf(`{"time":%q,"level":%q,"msg":`, now.Format(time.RFC3339Nano), level))
+	encoded, _ := json.Marshal(msg)
+	builder.Write(encoded)
+	for i, key := range keys {
+		encodedKey, _ := json.Marshal(key)
+		encodedValue, err := json.Marshal(values[i])
+		if err != nil {
+			encodedValue, _ = json.Marshal(fmt.Sprint(values[i]))
+		}
+		builder.WriteString(",")
+		builder.Write(encodedKey)
+		builder.WriteString(":")
+		builder.Write(encodedValue)
+	}
+	builder.WriteString("}")
+	return builder.String()
+}
+
+// Throttle allows an action at most once per interval, ex: progress lines of a producer
+type Throttle struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	last     time.Time
+}
+
+func NewThrottle(interval time.Duration) *Throttle {
+	return &Throttle{
+		interval: interval,
+	}
+}
+
+func (t *Throttle) Allow() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	now := time.Now()
+	if !t.last.IsZero() && now.Sub(t.last) < t.interval {
+		return false
+	}
+	t.last = now
+	return true
+}
+
+var root = &Logger{}
 
 func Errorf(str string, args ...interface{}) error {
 	return errors.New(fmt.Sprintf(str, args...))
 }
 
+func LogDebug(str string, args ...interface{}) {
+	root.log(LevelDebug, str, args...)
+}
+
 func LogInfo(str string, args ...interface{}) {
-	fmt.Printf("[info] %s\n", fmt.Sprintf(str, args...))
+	root.log(LevelInfo, str, args...)
+}
+
+func LogWarn(str string, args ...interface{}) {
+	root.log(LevelWarn, str, args...)
 }
 
 func LogErr(str string, args ...interface{}) {
-	fmt.Printf("[error] %s\n", fmt.Sprintf(str, args...))
+	root.log(LevelError, str, args...)
 }
 
 func LogBench() {
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AvailableMemory bytes of memory available to new processes without swapping, read from /proc/meminfo.
+// It fails on systems without /proc
+func AvailableMemory() (int64, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	// ex: MemAvailable:   12345678 kB
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemAvailable:" {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, Errorf("parse /proc/meminfo error: %s", err.Error())
+		}
+		return kb * 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, Errorf("no MemAvailable in /proc/meminfo")
+}
@@ -1,3 +1,3 @@
 # run Benchmark
 cd ../
-./bin/bench --type=tpch-std --qps=200000 --scale 1.0 --query 1
\ No newline at end of file
+./bin/bench query --qps=200000 --scale 1.0 --query 1
\ No newline at end of file
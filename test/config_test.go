@@ -0,0 +1,277 @@
+package test
+
+import (
+	"flag"
+	tpchbench "github.com/singularity-data/tpch-bench"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/exec"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTableStreams(t *testing.T) {
+	streams, err := configs.ParseTableStreams("customer:1,orders:10,lineitem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 3 || streams[1].Table != configs.Orders || streams[1].Ratio != 10 || streams[2].Ratio != 1 {
+		t.Errorf("unexpected streams: %+v", streams)
+	}
+	for _, s := range []string{"item:1", "orders:0", "orders:1,orders:2"} {
+		if _, err := configs.ParseTableStreams(s); err == nil {
+			t.Errorf("expect error parsing %s", s)
+		}
+	}
+
+	q3 := configs.NewTpchConfig(findQuery(t, "3"), 100000, 1.0)
+	if len(q3.Streams) != 2 || q3.Streams[0].Table != configs.LineItem || q3.Streams[1].Table != configs.Orders {
+		t.Errorf("q3 should stream lineitem and orders by default")
+	}
+	q2 := configs.NewTpchConfig(findQuery(t, "q2"), 100000, 1.0)
+	if len(q2.Streams) != 1 || q2.Streams[0].Table != configs.PartSupp {
+		t.Errorf("q2 should stream partsupp by default")
+	}
+}
+
+func TestLoadQuery(t *testing.T) {
+	dir := t.TempDir()
+	content := "# top customers\n" +
+		"# tables: customer, orders\n" +
+		"# mv: top_base, top_customers\n" +
+		"# streams: orders:1\n" +
+		"# expected-rows: 10\n" +
+		"# expected-result: top.expected\n" +
+		"statement\ncreate materialized view top_base as select * from orders\n"
+	path := filepath.Join(dir, "top.sql")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	query, err := configs.LoadQuery(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Name != "top" || query.MainTable != configs.Customer || query.ResultMV() != "top_customers" || len(query.MVs) != 2 {
+		t.Errorf("unexpected query: %+v", query)
+	}
+	if len(query.Streams) != 1 || query.ExpectedRows != 10 || query.ExpectedResult != filepath.Join(dir, "top.expected") {
+		t.Errorf("unexpected query: %+v", query)
+	}
+
+	// the result mv could be declared
+	content = strings.Replace(content, "# streams", "# result: top_base\n# streams", 1)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	query, err = configs.LoadQuery(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.ResultMV() != "top_base" {
+		t.Errorf("expect declared result mv top_base, found %s", query.ResultMV())
+	}
+
+	for i, header := range []string{"# tables: item\n", "# tables: orders\n# main: lineitem\n", "# streams: lineitem\n# tables: orders\n", "# rate: 1\n", "# result: top\n", "# sample: maybe\n"} {
+		bad := filepath.Join(dir, "bad.sql")
+		if err := os.WriteFile(bad, []byte(header+"statement\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := configs.LoadQuery(bad); err == nil {
+			t.Errorf("expect error loading header %d: %q", i, header)
+		}
+	}
+
+	// custom queries are discovered by name, files without header are not queries
+	configs.QueryDir = dir
+	defer func() {
+		configs.QueryDir = "../assets/data"
+	}()
+	_ = os.Remove(filepath.Join(dir, "bad.sql"))
+	_ = os.WriteFile(filepath.Join(dir, "create.sql"), []byte("statement\ncreate source s\n"), 0644)
+	queries, err := configs.DiscoverQueries(dir)
+	if err != nil || len(queries) != 1 {
+		t.Errorf("expect 1 query discovered, found %d, %v", len(queries), err)
+	}
+	if _, err := configs.FindQuery("top"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerySelection(t *testing.T) {
+	queries, err := configs.ParseQuerySelection("3, 5-7,q25,5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, query := range queries {
+		names = append(names, query.Name)
+	}
+	if strings.Join(names, ",") != "q3,q5,q6,q7,q25" {
+		t.Errorf("unexpected selection: %v", names)
+	}
+	all, err := configs.ParseQuerySelection("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) < 22 || all[0].Name != "q1" {
+		t.Errorf("all should select every discovered query in order")
+	}
+	for _, query := range all {
+		if query.Name == "q25" {
+			t.Errorf("all should leave out q25, its result is not sampled")
+		}
+	}
+	for _, spec := range []string{"3-1", "1,,2", "q99", "20-30"} {
+		if _, err := configs.ParseQuerySelection(spec); err == nil {
+			t.Errorf("%s should be invalid", spec)
+		}
+	}
+}
+
+func TestScenario(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scenario.json")
+	content := `{"target": {"pwd": "secret"}, "workload": {"qps": 100000, "queries": ["1", "5-7"], "scale": 0.5}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	scenario, err := configs.LoadScenario(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	qps := flags.Int("qps", 300000, "")
+	queries := flags.String("queries", "", "")
+	scale := flags.Float64("scale", 1.0, "")
+	flags.String("pwd", "", "")
+	if err := flags.Parse([]string{"--scale", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := scenario.Apply(flags); err != nil {
+		t.Fatal(err)
+	}
+	if *qps != 100000 || *queries != "1,5-7" || *scale != 2 {
+		t.Errorf("unexpected flags: qps[%d] queries[%s] scale[%f]", *qps, *queries, *scale)
+	}
+	resolved := configs.ResolveScenario(flags)
+	if _, ok := resolved["target"]["pwd"]; ok || resolved["workload"]["scale"] != "2" {
+		t.Errorf("unexpected resolved scenario: %v", resolved)
+	}
+
+	for _, invalid := range []string{
+		`{"workload": {"qsp": 1}}`,
+		`{"network": {}}`,
+		`{"workload": {"qps": {"value": 1}}}`,
+	} {
+		if err := os.WriteFile(path, []byte(invalid), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := configs.LoadScenario(path); err == nil {
+			t.Errorf("%s should be invalid", invalid)
+		}
+	}
+	flags = flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.Int("qps", 300000, "")
+	bad := configs.Scenario{"workload": {"qps": "fast"}}
+	if err := bad.Apply(flags); err == nil {
+		t.Errorf("qps should be an integer")
+	}
+}
+
+// tests run in ./test without ./assets, so default paths are read from the embedded files,
+// and files in AssetsDir override them
+func TestAssets(t *testing.T) {
+	defer func() {
+		configs.AssetsDir = ""
+	}()
+	dists, err := configs.ReadAsset("./assets/data/dists.dss")
+	if err != nil || len(dists) == 0 {
+		t.Fatalf("embedded distributions are not read: %v", err)
+	}
+	queries, err := configs.DiscoverQueries("./assets/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) < 22 {
+		t.Errorf("%d embedded queries are discovered", len(queries))
+	}
+	if _, err := configs.ReadAsset("./assets/data/q99.sql"); err == nil {
+		t.Errorf("q99 should not exist")
+	}
+
+	dir := t.TempDir()
+	custom := "# tables: orders\nstatement\ncreate materialized view tpch_q99 as select count(*) from orders;\n"
+	if err := os.WriteFile(filepath.Join(dir, "q99.sql"), []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "drop.sql"), []byte("custom"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configs.AssetsDir = dir
+	paths, err := configs.GlobAssets("./assets/data/q9*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{filepath.Join("assets", "data", "q9.sql"), filepath.Join("assets", "data", "q99.sql")}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("paths %v, expected %v", paths, expected)
+	}
+	query, err := configs.LoadQuery(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(query.Tables) != 1 || query.Tables[0] != configs.Orders {
+		t.Errorf("tables of the custom query: %v", query.Tables)
+	}
+	drop, err := configs.ReadAsset("./assets/data/drop.sql")
+	if err != nil || string(drop) != "custom" {
+		t.Errorf("drop.sql is not overridden: %q %v", drop, err)
+	}
+
+	// other paths are read from the file system only
+	if _, err := configs.ReadAsset(filepath.Join(dir, "q3.sql")); err == nil {
+		t.Errorf("q3 should not be read from the embedded files by an absolute path")
+	}
+}
+
+// checks of the doctor that need neither kafka nor the frontend
+func TestDoctorChecks(t *testing.T) {
+	mvs := tpchbench.DeclaredMVs()
+	if !mvs[exec.ProbeMV] || !mvs["tpch_q3"] {
+		t.Errorf("expect the probe mv and mvs of query files declared, found %v", mvs)
+	}
+
+	check := tpchbench.CheckLeftoverTopics([]string{"orders", "tpch_q3_sink", "other"})
+	if check.Err == nil || !strings.Contains(check.Err.Error(), "orders, tpch_q3_sink") || strings.Contains(check.Err.Error(), "other") {
+		t.Errorf("expect topics of tables and sinks left, found %v", check.Err)
+	}
+	if check = tpchbench.CheckLeftoverTopics([]string{"other"}); check.Err != nil {
+		t.Errorf("unexpected leftover topics: %s", check.Err.Error())
+	}
+
+	if check = tpchbench.CheckAssets(findQuery(t, "3")); check.Err != nil {
+		t.Errorf("assets of q3 should be readable: %s", check.Err.Error())
+	}
+	missing := *findQuery(t, "3")
+	missing.Path = "missing.sql"
+	if check = tpchbench.CheckAssets(&missing); check.Err == nil {
+		t.Errorf("expect error reading a missing query file")
+	}
+
+	// available memory is unknown without /proc/meminfo, the check only warns
+	poolSize := configs.TextPoolSize
+	defer func() {
+		configs.TextPoolSize = poolSize
+	}()
+	configs.TextPoolSize = 1 << 20
+	if check = tpchbench.CheckMemory(); check.Err != nil && !check.Warn {
+		t.Errorf("1 MiB text pool should fit in memory: %s", check.Err.Error())
+	}
+	configs.TextPoolSize = math.MaxInt
+	if check = tpchbench.CheckMemory(); check.Err == nil {
+		t.Errorf("expect error if the text pool does not fit in memory")
+	}
+}
@@ -1,9 +1,15 @@
 package test
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
 	"github.com/singularity-data/tpch-bench/pkg/data"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDistributionManager(t *testing.T) {
@@ -65,3 +71,243 @@ func TestTableGenerator(t *testing.T) {
 		fmt.Println(string(it.Next()))
 	}
 }
+
+// row counts planned without generators are the capacities of the generators
+func TestTableRowCount(t *testing.T) {
+	parts := 3
+	partsMap := make(map[configs.TpchTable]int)
+	for _, table := range configs.TpchAllTables {
+		partsMap[table] = parts
+	}
+	gen := data.NewTableGenerator(&data.TableGeneratorConfig{
+		ScaleFactor:   0.01,
+		TablePartsMap: partsMap,
+	})
+	for _, table := range configs.TpchAllTables {
+		for i := 0; i < parts; i++ {
+			rows, err := data.TableRowCount(table, 0.01, i+1, parts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if capacity := gen.GetSingleTableGenerator(table, i).Capacity(); rows != capacity {
+				t.Errorf("part %d of %s: %d rows, capacity %d", i+1, table, rows, capacity)
+			}
+		}
+	}
+}
+
+func TestSkewedGenerator(t *testing.T) {
+	configs.KeySkews = map[string]*configs.SkewSpec{
+		configs.SkewLPartKey: {Kind: configs.Zipf, Exponent: 1.2},
+	}
+	defer func() { configs.KeySkews = map[string]*configs.SkewSpec{} }()
+
+	// a generator advanced by AdvanceRows must continue the same sequence
+	gen := data.NewSkewedRandomLong(configs.SkewLPartKey, false, 1808217256, 1, 1, 200000)
+	values := make([]int64, 0)
+	for i := 0; i < 2000; i++ {
+		v, _ := gen.NextValue()
+		gen.FinishRow()
+		values = append(values, v)
+	}
+	advanced := data.NewSkewedRandomLong(configs.SkewLPartKey, false, 1808217256, 1, 1, 200000)
+	advanced.AdvanceRows(1000)
+	for i := 1000; i < 2000; i++ {
+		v, _ := advanced.NextValue()
+		advanced.FinishRow()
+		if v != values[i] {
+			t.Fatalf("row %d: expect %d after AdvanceRows, found %d", i, values[i], v)
+		}
+	}
+
+	hottest := 0
+	for _, v := range values {
+		if v < 1 || v > 200000 {
+			t.Fatalf("key %d out of range", v)
+		}
+		if v == 1 {
+			hottest++
+		}
+	}
+	if hottest < 100 {
+		t.Errorf("expect key 1 to be hot, found %d of %d", hottest, len(values))
+	}
+}
+
+// keys are counted per send, and counters of earlier generators are forgotten
+func TestKeyCounters(t *testing.T) {
+	configs.KeySkews = map[string]*configs.SkewSpec{
+		configs.SkewLPartKey: {Kind: configs.Zipf, Exponent: 1.2},
+	}
+	defer func() { configs.KeySkews = map[string]*configs.SkewSpec{} }()
+
+	data.ResetKeyCounters()
+	counter := data.NewKeyCounter(configs.SkewLPartKey)
+	counter.Add(1)
+	counter.Add(1)
+	if _, total := data.TopKeys(configs.SkewLPartKey, 10); total != 2 {
+		t.Errorf("expect 2 counted keys, found %d", total)
+	}
+	data.ClearKeyCounts()
+	counter.Add(3)
+	if freqs, total := data.TopKeys(configs.SkewLPartKey, 10); total != 1 || freqs[0].Key != 3 {
+		t.Errorf("expect only key 3 after clear, found %v of %d", freqs, total)
+	}
+	data.ResetKeyCounters()
+	if _, total := data.TopKeys(configs.SkewLPartKey, 10); total != 0 {
+		t.Errorf("expect no counted keys after reset, found %d", total)
+	}
+}
+
+func TestParseKeySkews(t *testing.T) {
+	skews, err := configs.ParseKeySkews("l_partkey=zipf:1.1,o_custkey=hotspot:0.01:0.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skews[configs.SkewLPartKey].Exponent != 1.1 || skews[configs.SkewOCustKey].HotRows != 0.9 {
+		t.Errorf("unexpected skews: %+v", skews)
+	}
+	for _, s := range []string{"l_partkey", "c_custkey=zipf:1", "l_partkey=zipf", "o_custkey=hotspot:2:0.5", "o_custkey=hotspot:0.1:0"} {
+		if _, err := configs.ParseKeySkews(s); err == nil {
+			t.Errorf("expect error parsing %s", s)
+		}
+	}
+}
+
+func TestDistributionDrift(t *testing.T) {
+	d, err := data.LoadDistributions("../assets/data/dists.dss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	points, err := configs.ParseDriftSchedule("1m:smode:TRUCK=0,AIR=10;10s:smode:AIR=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 || points[0].At != 10*time.Second {
+		t.Fatalf("drift points should be sorted by offset: %+v", points)
+	}
+	if err := d.ValidateDrift(points); err != nil {
+		t.Fatal(err)
+	}
+	bad, _ := configs.ParseDriftSchedule("10s:smode:BOAT=1")
+	if err := d.ValidateDrift(bad); err == nil {
+		t.Errorf("expect error drifting unknown term")
+	}
+
+	smode, _ := d.GetDistribution("smode")
+	err = smode.Drift(map[string]int{"REG AIR": 0, "AIR": 0, "RAIL": 0, "SHIP": 0, "TRUCK": 1, "MAIL": 0, "FOB": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := data.NewRandomInt(675466456, 100)
+	for i := 0; i < 100; i++ {
+		v, _ := smode.RandomValue(random)
+		if v != "TRUCK" {
+			t.Fatalf("expect TRUCK after drift, found %s", v)
+		}
+	}
+
+	// loaded weights are restored after the schedule
+	smode.ResetDrift()
+	others := 0
+	for i := 0; i < 100; i++ {
+		if v, _ := smode.RandomValue(random); v != "TRUCK" {
+			others++
+		}
+	}
+	if others == 0 {
+		t.Errorf("expect other modes after reset")
+	}
+}
+
+func TestLoadInvalidDistributions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dists.dss")
+	content := "# comment\nBEGIN smode\nCOUNT|2\nAIR|1\nTRUCK\nEND smode\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := data.LoadDistributions(path)
+	if err == nil || !strings.Contains(err.Error(), path+":5:") {
+		t.Errorf("expect error at line 5, found %v", err)
+	}
+
+	content = "BEGIN smode\nCOUNT|1\nAIR|1\nEND smode\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = data.LoadDistributions(path)
+	if err == nil || !strings.Contains(err.Error(), "required distribution") {
+		t.Errorf("expect missing required distributions, found %v", err)
+	}
+}
+
+func TestSeedOffset(t *testing.T) {
+	values := func() []int {
+		random := data.NewBoundedRandomInt(1066728069, 1, 1, 1000000)
+		re := make([]int, 0)
+		for i := 0; i < 10; i++ {
+			v, _ := random.NextValue()
+			random.FinishRow()
+			re = append(re, v)
+		}
+		return re
+	}
+	base := values()
+	configs.SeedOffset = 7
+	defer func() { configs.SeedOffset = 0 }()
+	perturbed := values()
+	if fmt.Sprint(base) == fmt.Sprint(perturbed) {
+		t.Errorf("seed offset should change generated values")
+	}
+	if fmt.Sprint(perturbed) != fmt.Sprint(values()) {
+		t.Errorf("seed offset should be deterministic")
+	}
+}
+
+func TestTextPoolCache(t *testing.T) {
+	d, err := data.LoadDistributions("../assets/data/dists.dss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := data.NewTextPool(d, data.MinTextPoolSize)
+	path := data.TextPoolCachePath(t.TempDir(), d, data.MinTextPoolSize)
+	if err := pool.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := data.OpenTextPool(path, data.MinTextPoolSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.GetSize() != pool.GetSize() || cached.GetText(0, pool.GetSize()) != pool.GetText(0, pool.GetSize()) {
+		t.Errorf("cached text pool differs from the built one")
+	}
+	if _, err := data.OpenTextPool(path, 2*data.MinTextPoolSize); err == nil {
+		t.Errorf("expect error opening text pool of a different size")
+	}
+}
+
+func TestUnboundedOrderGenerator(t *testing.T) {
+	gen := data.NewOrderGenerator(0.001, 2, 2)
+	gen.SetUnbounded()
+	for i := 0; i < 750; i++ {
+		gen.Next()
+	}
+
+	// the second round of part 2 starts at 1500 + 750
+	expected := data.NewOrderGeneratorIter(data.GetDistributionManager(), data.GetTextPool(), 2250, 750, 0.001)
+	for i := 0; i < 10; i++ {
+		row := gen.Next()
+		expectedRow, _ := json.Marshal(expected.Next())
+		if string(row) != string(expectedRow) {
+			t.Fatalf("expect %s, found %s", expectedRow, row)
+		}
+		var order data.Order
+		_ = json.Unmarshal(row, &order)
+		if order.OOrderkey != data.MakeOrderKey(int64(2250+i+1)) {
+			t.Errorf("unexpected order key %d of row %d", order.OOrderkey, 2250+i+1)
+		}
+		if order.OCustkey < 1 || order.OCustkey > 150 {
+			t.Errorf("customer key %d beyond the scale factor", order.OCustkey)
+		}
+	}
+}
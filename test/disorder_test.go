@@ -0,0 +1,103 @@
+package test
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/exec"
+	"testing"
+	"time"
+)
+
+func TestDisorder(t *testing.T) {
+	configs.DisorderFraction = 0.2
+	configs.DisorderMaxDelay = 100 * time.Millisecond
+	configs.LateFraction = 0.05
+	configs.LateDelay = time.Second
+	configs.ShuffleWindow = 8
+	defer func() {
+		configs.DisorderFraction = 0
+		configs.LateFraction = 0
+		configs.ShuffleWindow = 0
+	}()
+
+	d := exec.NewDisorder(1)
+	now := time.Now()
+	sent := 0
+	for i := 0; i < 10000; i++ {
+		sent += len(d.Admit(&kafka.Message{}, now))
+	}
+	sent += len(d.FlushWindow())
+	sent += len(d.Release(now.Add(configs.DisorderMaxDelay)))
+	if d.Pending() == 0 {
+		t.Errorf("late rows should still be held after max delay")
+	}
+	sent += len(d.Release(now.Add(configs.LateDelay)))
+	if sent != 10000 || d.Pending() != 0 {
+		t.Errorf("expect 10000 rows sent, found %d, pending %d", sent, d.Pending())
+	}
+
+	stats := d.Stats()
+	if stats.Late == 0 || stats.Delayed == 0 || stats.Shuffled == 0 {
+		t.Errorf("unexpected disorder stats: %+v", stats)
+	}
+	if stats.MaxDelay != configs.LateDelay {
+		t.Errorf("expect max delay %v, found %v", configs.LateDelay, stats.MaxDelay)
+	}
+
+	// rows held when the producer is interrupted are all sent at once
+	for i := 0; i < 1000; i++ {
+		sent += len(d.Admit(&kafka.Message{}, now))
+	}
+	sent += len(d.Drain(now))
+	if sent != 11000 || d.Pending() != 0 {
+		t.Errorf("expect 11000 rows sent after drain, found %d, pending %d", sent, d.Pending())
+	}
+}
+
+func TestCausalGate(t *testing.T) {
+	gate := exec.NewCausalGate(2)
+	if !gate.OrdersMayProceed(2) || gate.OrdersMayProceed(3) {
+		t.Errorf("orders should run ahead of lineitem by at most 2 orders")
+	}
+
+	// lineitem of order 1 waits until order 1 is flushed
+	sent := make(chan struct{})
+	go func() {
+		gate.WaitOrders(1)
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatalf("lineitem is sent before its order is flushed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	gate.PublishOrders(2)
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatalf("lineitem still waits after its order is flushed")
+	}
+	if !gate.OrdersMayProceed(3) || gate.OrdersMayProceed(4) {
+		t.Errorf("orders should proceed up to 2 orders ahead of the lineitem of order 1")
+	}
+	if stats := gate.Stats(); stats.MaxSkew != 1 || stats.LineItemWait == 0 {
+		t.Errorf("unexpected causal stats: %+v", stats)
+	}
+
+	// orders producer waits for lineitem, until lineitem producer finishes
+	waited := make(chan struct{})
+	go func() {
+		gate.WaitLineItems(10)
+		close(waited)
+	}()
+	gate.CloseLineItems()
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatalf("orders still wait after lineitem producer finishes")
+	}
+
+	// lineitem never waits after orders producer finishes
+	gate.CloseOrders()
+	gate.WaitOrders(1000)
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"context"
 	"fmt"
 	"github.com/singularity-data/tpch-bench/pkg/configs"
 	"github.com/singularity-data/tpch-bench/pkg/exec"
@@ -25,7 +26,7 @@ func TestDeleteKafkaTopic(t *testing.T) {
 
 func TestSendKafka(t *testing.T) {
 	util.LogInfo("------Prepare to run tpch query------")
-	tpchConfig := configs.NewTpchConfig(5, 100000, 1.0)
+	tpchConfig := configs.NewTpchConfig(findQuery(t, "5"), 100000, 1.0)
 
 	// create all topics in Kafka
 	err := exec.AdminTopics("create")
@@ -41,7 +42,7 @@ func TestSendKafka(t *testing.T) {
 	}
 
 	// send data rows of small tables in advance
-	kafkaExec.SendKafkaBatch()
+	kafkaExec.SendKafkaBatch(context.Background())
 }
 
 func TestSQLCreateKafkaSource(t *testing.T) {
@@ -0,0 +1,55 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bench.log")
+	closeLog, err := util.SetLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := util.SetLogFormat(util.LogJSON); err != nil {
+		t.Fatal(err)
+	}
+	util.SetLogLevel(util.LevelInfo)
+	util.SetField("query", "q3")
+	log := util.With("producer", 3, "table", "lineitem")
+	log.Debug("hidden")
+	log.Info("%d rows sent", 100)
+	util.SetField("query", "")
+	_ = util.SetLogFormat(util.LogText)
+	closeLog()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expect 1 line above the level, found %d", len(lines))
+	}
+	line := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["level"] != "info" || line["msg"] != "100 rows sent" || line["producer"] != 3.0 ||
+		line["table"] != "lineitem" || line["query"] != "q3" {
+		t.Errorf("unexpected line: %s", lines[0])
+	}
+
+	if _, err := util.ParseLevel("loud"); err == nil {
+		t.Errorf("loud should not be a level")
+	}
+	throttle := util.NewThrottle(time.Hour)
+	if !throttle.Allow() || throttle.Allow() {
+		t.Errorf("throttle should allow only the first action within the interval")
+	}
+}
@@ -0,0 +1,23 @@
+package test
+
+import (
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// tests run in ./test, and a small text pool keeps generator tests fast
+	configs.TpchDistributionPath = "../assets/data/dists.dss"
+	configs.QueryDir = "../assets/data"
+	configs.TextPoolSize = 1024 * 1024
+	os.Exit(m.Run())
+}
+
+func findQuery(t *testing.T, name string) *configs.Query {
+	query, err := configs.FindQuery(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return query
+}
@@ -0,0 +1,136 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/data"
+	"github.com/singularity-data/tpch-bench/pkg/exec"
+	"github.com/singularity-data/tpch-bench/pkg/metric"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	cases := map[float64]float64{50: 5, 90: 9, 99: 10, 100: 10, 1: 1}
+	for p, expected := range cases {
+		if v := metric.Percentile(values, p); v != expected {
+			t.Errorf("expect p%v %v, found %v", p, expected, v)
+		}
+	}
+	if v := metric.Percentile(nil, 50); v != 0 {
+		t.Errorf("expect 0 for no value, found %v", v)
+	}
+}
+
+func TestProbeLineItem(t *testing.T) {
+	item := &data.LineItem{}
+	err := json.Unmarshal(data.NewProbeLineItem(42, time.Now()), item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.LOrderkey != -42 || item.LPartkey >= 0 || item.LSuppkey >= 0 || item.LShipdate != data.ProbeShipDate {
+		t.Errorf("unexpected probe row: %+v", item)
+	}
+}
+
+func TestCorrelateLatencies(t *testing.T) {
+	base := time.Now()
+	at := func(ms int) time.Time {
+		return base.Add(time.Duration(ms) * time.Millisecond)
+	}
+	inputs := []time.Time{at(0), at(1000), at(2000), at(2200), at(5000)}
+	outputs := []exec.SinkOutput{
+		{Produced: at(-100), Received: at(-50)},  // before any input
+		{Produced: at(250), Received: at(300)},   // caused by input 0
+		{Produced: at(300), Received: at(350)},   // input 0 is credited already
+		{Produced: at(1100), Received: at(2100)}, // received after input 2 is flushed, but caused by input 1
+		{Produced: at(2400), Received: at(2500)}, // input 2 is superseded by input 3
+	}
+	latencies, unmatched := exec.CorrelateLatencies(inputs, outputs)
+	expected := []time.Duration{300 * time.Millisecond, 1100 * time.Millisecond, 300 * time.Millisecond}
+	if unmatched != 2 || len(latencies) != len(expected) {
+		t.Fatalf("expect %d latencies and 2 unmatched, found %v and %d", len(expected), latencies, unmatched)
+	}
+	for i := range expected {
+		if latencies[i] != expected[i] {
+			t.Errorf("expect latency %v of output change %d, found %v", expected[i], i, latencies[i])
+		}
+	}
+}
+
+func TestReadReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	manager := metric.NewMetricsManager()
+	manager.Record(metric.ResultMatched, 0)
+	report := manager.Report()
+	report.Scenario = map[string]map[string]string{"workload": {"query": "q3"}}
+	if err := report.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	suite, err := metric.ReadReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suite.Queries) != 1 || suite.Queries[0].Query != "q3" || suite.Queries[0].Status != metric.StatusMismatched || suite.Passed() {
+		t.Errorf("a run with a mismatched result should be read as a failed suite of q3")
+	}
+
+	report = metric.NewMetricsManager().Report()
+	report.SetStatus(fmt.Errorf("create sink error"))
+	if err := report.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	suite, err = metric.ReadReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suite.Queries[0].Status != metric.StatusFailed || suite.Queries[0].Error != "create sink error" || suite.Passed() {
+		t.Errorf("a run stopped by an error should be read as a failed suite, found %+v", suite.Queries[0])
+	}
+
+	suite = metric.NewSuiteReport()
+	suite.Queries = append(suite.Queries, &metric.QueryReport{Query: "q1", Status: metric.StatusPassed})
+	if err := suite.WriteReport(path); err != nil {
+		t.Fatal(err)
+	}
+	suite, err = metric.ReadReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suite.Queries) != 1 || !suite.Passed() {
+		t.Errorf("a suite of passed queries should pass")
+	}
+}
+
+func TestProgress(t *testing.T) {
+	now := time.Now()
+	if eta := exec.EstimateETA(1000, 100, time.Time{}, now); eta != 10*time.Second {
+		t.Errorf("expect eta 10s, found %v", eta)
+	}
+	if eta := exec.EstimateETA(1000, 100, now.Add(3*time.Second), now); eta != 3*time.Second {
+		t.Errorf("the deadline should bound the eta, found %v", eta)
+	}
+	if eta := exec.EstimateETA(math.MaxInt64, 0, time.Time{}, now); eta != -1 {
+		t.Errorf("eta of unbounded producers without a deadline should be unknown, found %v", eta)
+	}
+
+	snapshot := &exec.ProgressSnapshot{
+		Tables: []*exec.TableProgress{
+			{Table: configs.LineItem, Sent: 1200000, Capacity: 6000000},
+			{Table: configs.Orders, Sent: 10, Capacity: math.MaxInt64},
+		},
+		Rate:       298000,
+		TargetRate: 300000,
+		QueueLen:   1234,
+		ETA:        16 * time.Second,
+		MVRows:     10,
+	}
+	expected := "progress: lineitem[1200000/6000000 20.0%] orders[10/unbounded] rate[298000/300000 rows/s] queue[1234] eta[16s] mv rows[10]"
+	if snapshot.String() != expected {
+		t.Errorf("unexpected progress line: %s", snapshot.String())
+	}
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"context"
 	"github.com/singularity-data/tpch-bench/pkg/configs"
 	"github.com/singularity-data/tpch-bench/pkg/exec"
 	"github.com/singularity-data/tpch-bench/pkg/util"
@@ -8,11 +9,11 @@ import (
 )
 
 func TestProducerPerf(t *testing.T) {
-	tpchConfig := configs.NewTpchConfig(1, 360000, 2.0)
+	tpchConfig := configs.NewTpchConfig(findQuery(t, "1"), 360000, 2.0)
 	kafkaExec := exec.NewQueryKafkaExecutor(tpchConfig)
 	err := kafkaExec.Prepare()
 	if err != nil {
 		util.LogErr(err.Error())
 	}
-	kafkaExec.SendKafkaRealTime()
+	kafkaExec.SendKafkaRealTime(context.Background())
 }
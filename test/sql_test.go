@@ -0,0 +1,110 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	tpchbench "github.com/singularity-data/tpch-bench"
+	"github.com/singularity-data/tpch-bench/pkg/configs"
+	"github.com/singularity-data/tpch-bench/pkg/exec"
+	"github.com/singularity-data/tpch-bench/pkg/util"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// every tpch query is discovered, its file creates the declared mvs,
+// and its table mapping matches the tables the statements read
+func TestParseTpchQueries(t *testing.T) {
+	queries, err := configs.DiscoverQueries(configs.QueryDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[int]bool)
+	for _, query := range queries {
+		if id, ok := configs.TpchQueryId(query.Name); ok {
+			ids[id] = true
+		}
+		stmts, err := exec.ParseSQLFile(util.ReadFile(query.Path), query.Path)
+		if err != nil {
+			t.Errorf("parse %s error: %s", query.Name, err.Error())
+			continue
+		}
+		if len(stmts) != len(query.MVs) {
+			t.Errorf("expect %d statements in %s, found %d", len(query.MVs), query.Name, len(stmts))
+			continue
+		}
+		sql := ""
+		for i, stmt := range stmts {
+			text := strings.ToLower(stmt.SQL())
+			if stmt.IsQuery() || !strings.HasPrefix(text, fmt.Sprintf("create materialized view %s as", query.MVs[i])) {
+				t.Errorf("statement %d of %s does not create mv %s: %.50s", i, query.Name, query.MVs[i], text)
+			}
+			sql += text + "\n"
+		}
+
+		// table names in string literals are not references
+		sql = regexp.MustCompile(`'[^']*'`).ReplaceAllString(sql, "''")
+		involved := make(map[configs.TpchTable]bool)
+		for _, table := range query.Tables {
+			involved[table] = true
+		}
+		for _, table := range configs.TpchAllTables {
+			referenced := regexp.MustCompile(`\b` + string(table) + `\b`).MatchString(sql)
+			if referenced != involved[table] {
+				t.Errorf("%s references %s: %v, but it's involved in the header: %v", query.Name, table, referenced, involved[table])
+			}
+		}
+	}
+	for id := 1; id <= 22; id++ {
+		if !ids[id] {
+			t.Errorf("q%d is not discovered", id)
+		}
+	}
+}
+
+// the plan of a run shows row counts, producers and rendered ddl without connecting to anything
+func TestDryRun(t *testing.T) {
+	createPath, kafkaAddr := configs.SqlCreatePath, configs.KafkaAddrForFrontend
+	defer func() {
+		configs.SqlCreatePath, configs.KafkaAddrForFrontend = createPath, kafkaAddr
+	}()
+	configs.SqlCreatePath = "../assets/data/create_v2.sql"
+	configs.KafkaAddrForFrontend = "kafka:29092"
+
+	var out bytes.Buffer
+	err := tpchbench.DryRun(&out, "query", findQuery(t, "3"), 300000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := out.String()
+	for _, expected := range []string{
+		"main table: lineitem",
+		"lineitem       6000000  realtime",
+		"customer        150000  batch producers[1]",
+		"expected duration: ",
+		"'kafka.brokers'='kafka:29092'",
+		"create materialized view tpch_q3 as",
+	} {
+		if !strings.Contains(plan, expected) {
+			t.Errorf("plan does not contain %q:\n%s", expected, plan)
+		}
+	}
+	if strings.Contains(plan, "localhost:9092") {
+		t.Errorf("kafka address is not substituted:\n%s", plan)
+	}
+}
+
+// nation and region have a single generator, they are streamed by a single producer at any rate
+func TestStreamNation(t *testing.T) {
+	tpchConfig := configs.NewTpchConfig(findQuery(t, "5"), 300000, 1)
+	tpchConfig.Streams = []*configs.TableStream{{Table: configs.Nation, Ratio: 1}}
+	plan, err := exec.NewQueryKafkaExecutor(tpchConfig).Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, producer := range plan.Producers {
+		if producer.Table == configs.Nation && producer.Nums != 1 {
+			t.Errorf("expect nation streamed by 1 producer, found %d", producer.Nums)
+		}
+	}
+}